@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"os"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -13,6 +18,104 @@ import (
 	"github.com/localhots/blip/ctx/log"
 )
 
+//
+// Examples
+//
+
+// cardNumber matches candidate card numbers, digits optionally separated by
+// spaces or dashes.
+var cardNumber = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+// maskCards masks card numbers that pass the Luhn check, keeping the last four
+// digits.
+func maskCards(s string) string {
+	if !strings.ContainsAny(s, "0123456789") {
+		return s
+	}
+	return cardNumber.ReplaceAllStringFunc(s, func(m string) string {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, m)
+		if !luhnValid(digits) {
+			return m
+		}
+		return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	})
+}
+
+func luhnValid(digits string) bool {
+	var sum int
+	for i := range len(digits) {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func Example_valueRedactor() {
+	logger := blip.New(blip.Config{
+		Output:         os.Stdout,
+		Encoder:        &blip.ConsoleEncoder{SortFields: true},
+		ValueRedactor:  maskCards,
+		RedactMessages: true,
+	})
+	logger.Info(context.Background(), "Charged 4111 1111 1111 1111", log.F{
+		"card":  "4111-1111-1111-1111",
+		"order": "1234567890123", // Not a valid card number
+	})
+	// Output:
+	// INFO Charged ************1111  card=************1111 order=1234567890123
+}
+
+// kvEncoder is a minimal custom encoder writing entries as key=value pairs
+// separated with commas.
+type kvEncoder struct{}
+
+func (kvEncoder) Start(*blip.Buffer)                 {}
+func (kvEncoder) EncodeTime(*blip.Buffer)            {}
+func (kvEncoder) EncodeStackTrace(*blip.Buffer, int) {}
+func (kvEncoder) End(buf *blip.Buffer)               { buf.WriteBytes('\n') }
+
+func (kvEncoder) EncodeLevel(buf *blip.Buffer, lev blip.Level) {
+	buf.WriteKeyValue("level", '=', lev.String())
+}
+
+func (kvEncoder) EncodeMessage(buf *blip.Buffer, msg string) {
+	buf.WriteString(",msg=")
+	buf.WriteQuoted(msg)
+}
+
+func (kvEncoder) EncodeFields(buf *blip.Buffer, _ blip.Level, fields *[]blip.Field) {
+	if fields == nil {
+		return
+	}
+	buf.WriteBytes(' ')
+	first := true
+	for _, f := range *fields {
+		buf.WriteSeparatorIf(&first, ',')
+		buf.WriteKeyValue(f.Key, '=', fmt.Sprint(f.Value))
+	}
+}
+
+func Example_customEncoder() {
+	logger := blip.New(blip.Config{
+		Output:          os.Stdout,
+		Encoder:         kvEncoder{},
+		IncludeSequence: true,
+	})
+	logger.Info(context.Background(), "Task done", log.F{"task_id": 42})
+	// Output:
+	// level=info,msg="Task done" task_id=42,seq=1
+}
+
 //
 // Fuzz
 //
@@ -66,6 +169,41 @@ func FuzzBlip(f *testing.F) {
 	})
 }
 
+func FuzzFloat(f *testing.F) {
+	ctx := context.Background()
+
+	// Seed inputs
+	f.Add(0.0, false)
+	f.Add(1e20, false)
+	f.Add(1e21, false)
+	f.Add(1e-10, false)
+	f.Add(-math.MaxFloat64, false)
+	f.Add(math.SmallestNonzeroFloat64, false)
+	f.Add(math.NaN(), false)
+	f.Add(math.NaN(), true)
+	f.Add(math.Inf(1), false)
+	f.Add(math.Inf(1), true)
+	f.Add(math.Inf(-1), false)
+	f.Add(math.Inf(-1), true)
+
+	f.Fuzz(func(t *testing.T, val float64, null bool) {
+		var buf bytes.Buffer
+		enc := blip.NewJSONEncoder()
+		if null {
+			enc.NonFiniteFloats = blip.NonFiniteNull
+		}
+		logger := blip.New(blip.Config{Output: &buf, Encoder: enc})
+		logger.Info(ctx, "Float", log.F{"f64": val, "f32": float32(val), "slice": []float64{val}})
+
+		var out map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Logf("val=%v", val)
+			t.Log(buf.String())
+			t.Errorf("Failed to unmarshal JSON: %v", err)
+		}
+	})
+}
+
 //
 // Benchmarks
 //
@@ -110,6 +248,21 @@ func BenchmarkBare(b *testing.B) {
 	}
 }
 
+func BenchmarkNoFields(b *testing.B) {
+	log.Setup(blip.Config{
+		Level:           blip.LevelDebug,
+		Output:          io.Discard,
+		StackTraceLevel: blip.LevelError,
+		Encoder:         &blip.ConsoleEncoder{},
+	})
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for range b.N {
+		log.Info(ctx, "Message without fields")
+	}
+}
+
 func BenchmarkOptimized(b *testing.B) {
 	log.Setup(blip.Config{
 		Level:  blip.LevelDebug,
@@ -188,6 +341,34 @@ func BenchmarkPrettySorted(b *testing.B) {
 	}
 }
 
+type benchStatus int
+
+func (s benchStatus) String() string {
+	return "active"
+}
+
+func BenchmarkStringer(b *testing.B) {
+	log.Setup(blip.Config{
+		Level:  blip.LevelDebug,
+		Output: io.Discard,
+		Encoder: &blip.ConsoleEncoder{
+			TimeFormat:    "2006-01-02 15:04:05.000",
+			TimePrecision: 1 * time.Millisecond,
+		},
+		StackTraceLevel: blip.LevelError,
+	})
+	ctx := context.Background()
+	err := errors.New("task already exists")
+
+	b.ResetTimer()
+	for range b.N {
+		log.Info(ctx, "Starting task", log.F{
+			"status": benchStatus(1),
+			"error":  err,
+		})
+	}
+}
+
 func BenchmarkPrettySortedContext(b *testing.B) {
 	log.Setup(blip.Config{
 		Level:  blip.LevelDebug,
@@ -215,3 +396,181 @@ func BenchmarkPrettySortedContext(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkLargeMessage(b *testing.B) {
+	log.Setup(blip.Config{
+		Level:           blip.LevelDebug,
+		Output:          io.Discard,
+		StackTraceLevel: blip.LevelError,
+		Encoder:         blip.NewJSONEncoder(),
+	})
+	ctx := context.Background()
+	// Stack trace-like message that needs escaping
+	msg := strings.Repeat("main.handler\n\t/app/main.go:42\n", 512)
+
+	b.ResetTimer()
+	for range b.N {
+		log.Info(ctx, msg)
+	}
+}
+
+func BenchmarkPools(b *testing.B) {
+	for _, name := range []string{"shared", "private", "sharded"} {
+		b.Run(name, func(b *testing.B) {
+			loggers := make([]*blip.Logger, 8)
+			for i := range loggers {
+				loggers[i] = blip.New(blip.Config{
+					Output:       io.Discard,
+					Encoder:      blip.NewJSONEncoder(),
+					PrivatePools: name == "private",
+					ShardedPools: name == "sharded",
+				})
+			}
+			ctx := context.Background()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					loggers[i%len(loggers)].Info(ctx, "Starting task", blip.F{
+						"task_id": 123456,
+						"status":  "success",
+					})
+				}
+			})
+		})
+	}
+}
+
+// namedMap is not matched by the map fast paths and is encoded using the
+// reflection-based fallback.
+type namedMap map[string]string
+
+func BenchmarkMapField(b *testing.B) {
+	m := map[string]string{
+		"Accept":       "application/json",
+		"Content-Type": "application/json",
+		"User-Agent":   "blip",
+	}
+	encoders := map[string]blip.Encoder{
+		"json":    blip.NewJSONEncoder(),
+		"console": &blip.ConsoleEncoder{},
+	}
+	values := map[string]any{
+		"fast":       m,
+		"reflection": namedMap(m),
+	}
+	for _, encName := range []string{"json", "console"} {
+		for _, valName := range []string{"fast", "reflection"} {
+			b.Run(encName+"/"+valName, func(b *testing.B) {
+				logger := blip.New(blip.Config{Output: io.Discard, Encoder: encoders[encName]})
+				ctx := context.Background()
+				val := values[valName]
+
+				b.ResetTimer()
+				for range b.N {
+					logger.Info(ctx, "Request", blip.F{"headers": val})
+				}
+			})
+		}
+	}
+}
+
+// namedInts is not matched by the slice fast paths and is encoded using the
+// reflection-based fallback.
+type namedInts []int
+
+func BenchmarkSliceField(b *testing.B) {
+	ints := make([]int, 100)
+	for i := range ints {
+		ints[i] = i * 1000
+	}
+	encoders := map[string]blip.Encoder{
+		"json":    blip.NewJSONEncoder(),
+		"console": &blip.ConsoleEncoder{},
+	}
+	values := map[string]any{
+		"fast":       ints,
+		"reflection": namedInts(ints),
+	}
+	for _, encName := range []string{"json", "console"} {
+		for _, valName := range []string{"fast", "reflection"} {
+			b.Run(encName+"/"+valName, func(b *testing.B) {
+				logger := blip.New(blip.Config{Output: io.Discard, Encoder: encoders[encName]})
+				ctx := context.Background()
+				val := values[valName]
+
+				b.ResetTimer()
+				for range b.N {
+					logger.Info(ctx, "Batch", blip.F{"ids": val})
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCause(b *testing.B) {
+	log.Setup(blip.Config{
+		Level:           blip.LevelDebug,
+		Output:          io.Discard,
+		StackTraceLevel: blip.LevelFatal,
+		Encoder:         &blip.ConsoleEncoder{},
+	})
+	ctx := context.Background()
+	err := errors.New("task already exists")
+
+	b.ResetTimer()
+	for range b.N {
+		log.Error(ctx, "Failed to process task", log.Cause(err), log.F{
+			"task_id": 123456,
+		})
+	}
+}
+
+func BenchmarkCtxCached(b *testing.B) {
+	logger := blip.New(blip.Config{
+		Level:           blip.LevelDebug,
+		Output:          io.Discard,
+		StackTraceLevel: blip.LevelFatal,
+		Encoder:         &blip.ConsoleEncoder{},
+	})
+	// A request handler context carrying values of middleware and libraries
+	ctx := blip.ContextWithFields(context.Background(), log.F{"request_id": "abc"})
+	for i := range 10 {
+		ctx = context.WithValue(ctx, ctxKey(i), i)
+	}
+	fields := log.F{"task_id": 123456}
+
+	b.Run("ctx", func(b *testing.B) {
+		for range b.N {
+			logger.Info(ctx, "Processing task", fields)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		log := logger.Ctx(ctx).Cached()
+		b.ResetTimer()
+		for range b.N {
+			log.Info("Processing task", fields)
+		}
+	})
+}
+
+type ctxKey int
+
+func BenchmarkDiscard(b *testing.B) {
+	for _, out := range []struct {
+		name string
+		w    io.Writer
+	}{
+		{"io.Discard", io.Discard},
+		{"blip.Discard", blip.Discard},
+	} {
+		b.Run(out.name, func(b *testing.B) {
+			logger := blip.New(blip.Config{Output: out.w, Encoder: &blip.ConsoleEncoder{}})
+			ctx := context.Background()
+			b.ResetTimer()
+			for range b.N {
+				logger.Info(ctx, "Callback received", log.F{"task_id": 123456})
+			}
+		})
+	}
+}
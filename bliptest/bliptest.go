@@ -0,0 +1,63 @@
+// Package bliptest provides helpers for testing code that logs with blip.
+package bliptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ValidateJSON checks that every line of the output produced by the JSON
+// encoder is a JSON object with unique keys that contains all of the required
+// keys. A field named after one of the encoder's reserved keys (see
+// JSONEncoder.ReservedKeys) is reported as a duplicate key.
+func ValidateJSON(output []byte, required ...string) error {
+	lines := bytes.Split(bytes.TrimRight(output, "\n"), []byte{'\n'})
+	for i, line := range lines {
+		if err := validateLine(line, required); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateLine(line []byte, required []string) error {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return errors.New("not a JSON object")
+	}
+
+	seen := make(map[string]struct{})
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("duplicate key %q", key)
+		}
+		seen[key] = struct{}{}
+
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after JSON object")
+	}
+
+	for _, key := range required {
+		if _, ok := seen[key]; !ok {
+			return fmt.Errorf("missing key %q", key)
+		}
+	}
+	return nil
+}
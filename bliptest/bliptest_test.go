@@ -0,0 +1,57 @@
+package bliptest
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/localhots/blip"
+)
+
+func TestValidateJSON(t *testing.T) {
+	var buf bytes.Buffer
+	enc := blip.NewJSONEncoder()
+	logger := blip.New(blip.Config{Output: &buf, Encoder: enc})
+	ctx := context.Background()
+
+	logger.Info(ctx, "Starting task", blip.F{"task_id": 123456})
+	logger.Warn(ctx, "Retrying task")
+	if err := ValidateJSON(buf.Bytes(), enc.ReservedKeys()[:3]...); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateJSONCollision(t *testing.T) {
+	var buf bytes.Buffer
+	enc := blip.NewJSONEncoder()
+	logger := blip.New(blip.Config{Output: &buf, Encoder: enc})
+	ctx := context.Background()
+
+	logger.Info(ctx, "Starting task")
+	logger.Info(ctx, "Starting task", blip.F{enc.KeyMessage: "shadowed"})
+	err := ValidateJSON(buf.Bytes(), enc.ReservedKeys()[:3]...)
+	if err == nil || err.Error() != `line 2: duplicate key "message"` {
+		t.Errorf("expected duplicate key error, got %v", err)
+	}
+}
+
+func TestValidateJSONMissingKey(t *testing.T) {
+	var buf bytes.Buffer
+	enc := blip.NewJSONEncoder()
+	enc.TimeFormat = ""
+	logger := blip.New(blip.Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "Starting task")
+	err := ValidateJSON(buf.Bytes(), enc.ReservedKeys()[:3]...)
+	if err == nil || err.Error() != `line 1: missing key "time"` {
+		t.Errorf("expected missing key error, got %v", err)
+	}
+}
+
+func TestValidateJSONInvalid(t *testing.T) {
+	for _, in := range []string{`[1,2]`, `{"a":1`, `{"a":1}{}`, `plain text`} {
+		if err := ValidateJSON([]byte(in)); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
@@ -2,8 +2,8 @@ package blip
 
 import (
 	"encoding/base64"
+	"slices"
 	"strconv"
-	"sync"
 	"time"
 	"unicode/utf8"
 )
@@ -11,6 +11,10 @@ import (
 // Buffer is a byte buffer used for encoding log entries.
 // WARNING: Buffer should not be initialized manually. It is pooled to reduce
 // allocations.
+//
+// Custom encoders write entries with its exported methods: the Write methods
+// for values, WriteEscapedString and WriteQuoted for quoted strings, and
+// WriteKeyValue and WriteSeparatorIf to compose lists of fields.
 type Buffer struct {
 	b []byte
 }
@@ -68,8 +72,18 @@ func (buf *Buffer) WriteDuration(d time.Duration) {
 }
 
 // WriteTime writes a time.Time value to the buffer using the specified format.
+// The Unix presets, like TimeFormatUnix, are written as integers.
 func (buf *Buffer) WriteTime(t time.Time, format string) {
-	buf.b = t.AppendFormat(buf.b, format)
+	switch format {
+	case TimeFormatUnix:
+		buf.WriteInt(t.Unix())
+	case TimeFormatUnixMilli:
+		buf.WriteInt(t.UnixMilli())
+	case TimeFormatUnixNano:
+		buf.WriteInt(t.UnixNano())
+	default:
+		buf.b = t.AppendFormat(buf.b, format)
+	}
 }
 
 // WriteEscapedString writes a string to the buffer, escaping special characters
@@ -110,6 +124,41 @@ func (buf *Buffer) WriteEscapedString(str string) {
 	buf.WriteBytes('"')
 }
 
+// WriteQuoted writes a string enclosed in double quotes without escaping it.
+// Use it for strings known not to need escaping, e.g. constant keys, and
+// WriteEscapedString otherwise.
+func (buf *Buffer) WriteQuoted(str string) {
+	buf.Grow(len(str) + 2)
+	buf.WriteBytes('"')
+	buf.WriteString(str)
+	buf.WriteBytes('"')
+}
+
+// WriteKeyValue writes a key and a value separated with sep, e.g. key=value,
+// without quoting or escaping them.
+func (buf *Buffer) WriteKeyValue(key string, sep byte, val string) {
+	buf.Grow(len(key) + len(val) + 1)
+	buf.WriteString(key)
+	buf.WriteBytes(sep)
+	buf.WriteString(val)
+}
+
+// WriteSeparatorIf writes sep unless first is set, and clears first. Call it
+// before writing every element of a list to separate the elements:
+//
+//	first := true
+//	for _, f := range *fields {
+//		buf.WriteSeparatorIf(&first, ',')
+//		// Write the field
+//	}
+func (buf *Buffer) WriteSeparatorIf(first *bool, sep byte) {
+	if *first {
+		*first = false
+		return
+	}
+	buf.WriteBytes(sep)
+}
+
 // WriteBase64 writes a byte slice to the buffer as a base64-encoded string.
 func (buf *Buffer) WriteBase64(b64enc *base64.Encoding, data []byte) {
 	buf.WriteBytes('"')
@@ -117,6 +166,24 @@ func (buf *Buffer) WriteBase64(b64enc *base64.Encoding, data []byte) {
 	buf.WriteBytes('"')
 }
 
+// Grow grows the buffer's capacity, if necessary, to guarantee space for
+// another n bytes. Encoders can use it to avoid repeated reallocations when
+// the size of the output is known in advance.
+func (buf *Buffer) Grow(n int) {
+	buf.b = slices.Grow(buf.b, n)
+}
+
+// Len returns the number of bytes written to the buffer.
+func (buf *Buffer) Len() int {
+	return len(buf.b)
+}
+
+// Bytes returns the contents of the buffer. The slice is only valid until the
+// next write to the buffer.
+func (buf *Buffer) Bytes() []byte {
+	return buf.b
+}
+
 func (buf *Buffer) writeEscapedASCII(b byte) {
 	switch b {
 	case '"', '\\':
@@ -141,36 +208,11 @@ func (buf *Buffer) writeEscapedUTF8(str string) int {
 	if r == utf8.RuneError && size == 1 {
 		// \uFFFD is the replacement character for invalid UTF-8 sequences (�).
 		// It looks like a diamond with a question mark inside.
+		// Each byte of an invalid sequence is replaced separately, which
+		// matches the behavior of encoding/json.
 		buf.WriteBytes('\\', 'u', 'f', 'f', 'f', 'd')
 		return 1
 	}
 	buf.WriteRune(r)
 	return size
 }
-
-//
-// Buffer pool
-//
-
-// Buffers are pooled to reduce allocations.
-var bufferPool = sync.Pool{
-	New: func() any {
-		return &Buffer{make([]byte, 0, bufferSize)}
-	},
-}
-
-func getBuffer() *Buffer {
-	buf, _ := bufferPool.Get().(*Buffer)
-	return buf
-}
-
-func putBuffer(buf *Buffer) {
-	const maxCap = 10 * bufferSize
-	if cap(buf.b) > maxCap {
-		// If the buffer is too large, let it get garbage collected.
-		// This avoids keeping large buffers in the pool to reduce memory usage.
-		return
-	}
-	buf.b = buf.b[:0] // Reset the underlying slice
-	bufferPool.Put(buf)
-}
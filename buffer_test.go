@@ -0,0 +1,106 @@
+package blip
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteEscapedStringInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		// Lone surrogate halves, as produced by CESU-8, are invalid in UTF-8.
+		// Like encoding/json, every byte of an invalid sequence is replaced.
+		{"\xED\xA0\x80", `"\ufffd\ufffd\ufffd"`},
+		{"\xED\xB0\x80", `"\ufffd\ufffd\ufffd"`},
+		{"\xED\xA0\x80\xED\xB0\x80", `"\ufffd\ufffd\ufffd\ufffd\ufffd\ufffd"`},
+		{"a\xED\xA0\x80b", `"a\ufffd\ufffd\ufffdb"`},
+		// Truncated sequences
+		{"\xE2\x82", `"\ufffd\ufffd"`},
+		{"\xF0\x9F\x98", `"\ufffd\ufffd\ufffd"`},
+		// Valid surrogate pair encoded properly as a 4 byte sequence
+		{"\xF0\x9F\x98\x80", `"😀"`},
+	}
+	for _, tt := range tests {
+		buf := sharedPools.getBuffer()
+		buf.WriteEscapedString(tt.in)
+		if got := string(buf.b); got != tt.exp {
+			t.Errorf("input %q: expected %s, got %s", tt.in, tt.exp, got)
+		}
+
+		// Must decode to the same string as encoding/json output
+		std, err := json.Marshal(tt.in)
+		if err != nil {
+			t.Fatalf("Failed to marshal JSON: %v", err)
+		}
+		var exp, got string
+		if err := json.Unmarshal(std, &exp); err != nil {
+			t.Fatalf("Failed to unmarshal JSON: %v", err)
+		}
+		if err := json.Unmarshal(buf.b, &got); err != nil {
+			t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.b)
+		}
+		if got != exp {
+			t.Errorf("input %q: expected %q as encoding/json, got %q", tt.in, exp, got)
+		}
+		sharedPools.putBuffer(buf)
+	}
+}
+
+func TestBufferGrow(t *testing.T) {
+	buf := &Buffer{make([]byte, 0, 16)}
+	buf.WriteString("hello")
+
+	buf.Grow(8)
+	if cap(buf.b) != 16 {
+		t.Errorf("expected capacity to stay 16, got %d", cap(buf.b))
+	}
+
+	buf.Grow(100)
+	if cap(buf.b)-len(buf.b) < 100 {
+		t.Errorf("expected room for 100 bytes, got %d", cap(buf.b)-len(buf.b))
+	}
+	if string(buf.b) != "hello" {
+		t.Errorf("expected contents to be preserved, got %q", buf.b)
+	}
+
+	c := cap(buf.b)
+	for range 100 {
+		buf.WriteBytes('a')
+	}
+	if cap(buf.b) != c {
+		t.Errorf("expected no reallocation, capacity changed from %d to %d", c, cap(buf.b))
+	}
+}
+
+func TestBufferHelpers(t *testing.T) {
+	buf := &Buffer{}
+	buf.WriteQuoted("key")
+	buf.WriteBytes(':')
+	buf.WriteQuoted(`a "b"`)
+	if exp := `"key":"a "b""`; string(buf.b) != exp {
+		t.Errorf("expected %s, got %s", exp, buf.b)
+	}
+
+	buf = &Buffer{}
+	first := true
+	for _, kv := range [][2]string{{"a", "1"}, {"b", "2"}, {"c", ""}} {
+		buf.WriteSeparatorIf(&first, ' ')
+		buf.WriteKeyValue(kv[0], '=', kv[1])
+	}
+	if exp := "a=1 b=2 c="; string(buf.b) != exp {
+		t.Errorf("expected %q, got %q", exp, buf.b)
+	}
+	if first {
+		t.Error("expected first to be cleared")
+	}
+
+	// Nothing is written for the first element
+	buf = &Buffer{}
+	first = true
+	buf.WriteSeparatorIf(&first, ',')
+	if buf.Len() != 0 {
+		t.Errorf("expected no separator, got %q", buf.b)
+	}
+}
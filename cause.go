@@ -0,0 +1,82 @@
+package blip
+
+import "errors"
+
+// ErrorFieldKey is the key Cause logs errors under, their stack traces are
+// logged under the key with a "_stack" suffix. It also is the default of
+// Config.StackTraceErrorKey. Set it before creating loggers.
+var ErrorFieldKey = defaultErrorKey
+
+// Cause returns a field set with the error message under the "error" key, see
+// ErrorFieldKey. If the error, or an error it wraps, carries the stack trace
+// of where it was created, the stack trace is added under the "error_stack"
+// key. Errors implementing StackTrace() []uintptr are supported, errors created
+// with github.com/pkg/errors are logged with Cause of the pkgerrors module.
+//
+// The error is wrapped in an error with the same message, which is expanded
+// into these fields when the entry is logged. This keeps Cause small enough to
+// be inlined, so the returned field set doesn't escape to the heap.
+func Cause(err error) F {
+	return F{ErrorFieldKey: causeError{err}}
+}
+
+// CauseWithKey is like Cause, but logs the error under the given key and its
+// stack trace under the key with a "_stack" suffix.
+func CauseWithKey(key string, err error) F {
+	return F{key: causeError{err}}
+}
+
+// causeError is an error wrapped with Cause or CauseWithKey.
+type causeError struct {
+	err error
+}
+
+func (e causeError) Error() string {
+	if e.err == nil {
+		return "<nil>"
+	}
+	return e.err.Error()
+}
+
+func (e causeError) Unwrap() error { return e.err }
+
+// addCause adds the fields of an error wrapped with Cause or CauseWithKey. The
+// error itself is used as the value, encoders write its message. The stack
+// trace is limited to maxFrames frames.
+func addCause(f *[]Field, norm func(string) string, maxFrames int, key string, err error) {
+	addField(f, norm, key, err)
+	if err == nil {
+		return
+	}
+	if st := errorStack(err, maxFrames); st != "" {
+		addField(f, norm, key+"_stack", st)
+	}
+}
+
+// errorStack returns the formatted stack trace of the innermost error in the
+// chain that has one, which is the closest to where the error occurred.
+func errorStack(err error, maxFrames int) string {
+	var pc []uintptr
+	for ; err != nil; err = errors.Unwrap(err) {
+		if st := errorStackPCs(err); st != nil {
+			pc = st
+		}
+	}
+	if pc == nil {
+		return ""
+	}
+	return formatFrames(pc, maxFrames)
+}
+
+// stackTracer is implemented by errors that carry the stack trace of where
+// they were created.
+type stackTracer interface {
+	StackTrace() []uintptr
+}
+
+func errorStackPCs(err error) []uintptr {
+	if st, ok := err.(stackTracer); ok {
+		return st.StackTrace()
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// causeFields returns the fields an error wrapped with Cause is expanded into.
+func causeFields(err error) map[string]any {
+	fields, _ := makeFields(context.Background(), LevelInfo, sharedPools, nil, defaultMaxFrames, "", nil, []F{Cause(err)})
+	f := map[string]any{}
+	for _, fld := range *fields {
+		f[fld.Key] = fld.Value
+	}
+	return f
+}
+
+func TestCause(t *testing.T) {
+	err := errors.New("task failed")
+	f := causeFields(err)
+	if f["error"] != err {
+		t.Errorf("expected error message, got %v", f["error"])
+	}
+	if _, ok := f["error_stack"]; ok {
+		t.Errorf("unexpected error stack: %v", f["error_stack"])
+	}
+}
+
+func TestCauseFieldSet(t *testing.T) {
+	// The error is visible under its key before the entry is logged
+	err := errors.New("task failed")
+	f := Cause(err)
+	if len(f) != 1 {
+		t.Fatalf("expected a single field, got %v", f)
+	}
+	cause, ok := f["error"].(error)
+	if !ok || cause.Error() != "task failed" || !errors.Is(cause, err) {
+		t.Errorf("expected the error under the error key, got %v", f["error"])
+	}
+	if f := CauseWithKey("err", err); !errors.Is(f["err"].(error), err) {
+		t.Errorf("expected the error under the custom key, got %v", f)
+	}
+}
+
+type stackError struct {
+	pc []uintptr
+}
+
+func (e stackError) Error() string         { return "task failed" }
+func (e stackError) StackTrace() []uintptr { return e.pc }
+
+func TestCauseStackTracer(t *testing.T) {
+	pc := make([]uintptr, 10)
+	n := runtime.Callers(1, pc)
+	f := causeFields(stackError{pc[:n]})
+
+	st, _ := f["error_stack"].(string)
+	if !strings.HasPrefix(st, "github.com/localhots/blip.TestCauseStackTracer\n\t") {
+		t.Errorf("expected stack trace to start at the test, got:\n%s", st)
+	}
+}
+
+func TestCauseNormalized(t *testing.T) {
+	ctx := ContextWithFields(context.Background(), Cause(errors.New("task failed")))
+	fields, _ := makeFields(ctx, LevelInfo, sharedPools, strings.ToUpper, defaultMaxFrames, "", nil, []F{{"task_id": 1}})
+	if len(*fields) != 2 || (*fields)[0].Key != "ERROR" {
+		t.Errorf("expected error from context fields with normalized key, got %v", *fields)
+	}
+}
+
+func TestCauseWithKey(t *testing.T) {
+	pc := make([]uintptr, 10)
+	n := runtime.Callers(1, pc)
+	err := stackError{pc[:n]}
+	fields, _ := makeFields(context.Background(), LevelInfo, sharedPools, nil, defaultMaxFrames, "", nil, []F{CauseWithKey("err", err)})
+	f := map[string]any{}
+	for _, fld := range *fields {
+		f[fld.Key] = fld.Value
+	}
+	if _, ok := f["err"].(stackError); !ok {
+		t.Errorf("expected error under the custom key, got %v", f)
+	}
+	if _, ok := f["err_stack"].(string); !ok {
+		t.Errorf("expected error stack under the custom key, got %v", f)
+	}
+	if _, ok := f["error"]; ok {
+		t.Errorf("unexpected default key: %v", f)
+	}
+}
+
+func TestErrorFieldKey(t *testing.T) {
+	defer func(key string) { ErrorFieldKey = key }(ErrorFieldKey)
+	ErrorFieldKey = "err"
+
+	var buf bytes.Buffer
+	logger := New(Config{
+		Output:                  &buf,
+		Encoder:                 &ConsoleEncoder{},
+		StackTraceLevel:         LevelError,
+		StackTraceOnlyWithError: true,
+	})
+	logger.Error(context.Background(), "Task failed", Cause(errors.New("boom")))
+	if !strings.HasPrefix(buf.String(), "ERRO Task failed  err=boom\n") {
+		t.Errorf("expected error under the custom key, got %q", buf.String())
+	}
+	// The stack trace is keyed off the same field
+	if !strings.Contains(buf.String(), "TestErrorFieldKey") {
+		t.Errorf("expected a stack trace, got %q", buf.String())
+	}
+}
@@ -0,0 +1,120 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// closeRecorder counts written entries and fails writes after it is closed.
+type closeRecorder struct {
+	lock    sync.Mutex
+	entries map[string]int
+	closed  bool
+}
+
+func (r *closeRecorder) Write(b []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return 0, errors.New("write after close")
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(b), []byte{'\n'}) {
+		r.entries[string(line)]++
+	}
+	return len(b), nil
+}
+
+func (r *closeRecorder) Close() error {
+	r.lock.Lock()
+	r.closed = true
+	r.lock.Unlock()
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	out := &closeRecorder{entries: map[string]int{}}
+	logger := New(Config{Output: out, Encoder: &ConsoleEncoder{}})
+	ctx := context.Background()
+
+	const goroutines, entries = 8, 200
+	var before, during sync.WaitGroup
+	var lock sync.Mutex
+	var failed int
+	for range goroutines {
+		before.Add(1)
+		during.Add(1)
+		go func() {
+			defer during.Done()
+			for range entries {
+				logger.Info(ctx, "Before close")
+			}
+			before.Done()
+			for range entries {
+				if err := logger.Sync(ctx, LevelInfo, "During close"); err != nil {
+					if !errors.Is(err, ErrClosed) {
+						t.Errorf("expected ErrClosed, got %v", err)
+					}
+					lock.Lock()
+					failed++
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	before.Wait()
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	during.Wait()
+	if !out.closed {
+		t.Error("expected the output to be closed")
+	}
+
+	if n := out.entries["INFO Before close"]; n != goroutines*entries {
+		t.Errorf("expected %d entries logged before close, got %d", goroutines*entries, n)
+	}
+	written := out.entries["INFO During close"]
+	if dropped := logger.Dropped(); written+int(dropped) != goroutines*entries || int(dropped) != failed {
+		t.Errorf("expected %d entries written or dropped, got %d written, %d dropped, %d failed",
+			goroutines*entries, written, dropped, failed)
+	}
+	if n := logger.Counts()[LevelInfo]; n != uint64(goroutines*entries+written) {
+		t.Errorf("expected %d counted entries, got %d", goroutines*entries+written, n)
+	}
+
+	// Logging after close is dropped, closing again does nothing
+	logger.Info(ctx, "After close")
+	if err := logger.WriteRaw([]byte("raw")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if dropped := logger.Dropped(); int(dropped) != failed+2 {
+		t.Errorf("expected %d dropped entries, got %d", failed+2, dropped)
+	}
+	if err := logger.Close(); err != nil {
+		t.Errorf("expected no error closing twice, got %v", err)
+	}
+}
+
+func TestCloseReplay(t *testing.T) {
+	rec := &Recorder{}
+	source := New(Config{Output: &closeRecorder{entries: map[string]int{}}, Encoder: rec})
+	source.Info(context.Background(), "Recorded")
+
+	out := &closeRecorder{entries: map[string]int{}}
+	logger := New(Config{Output: out, Encoder: &ConsoleEncoder{}})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if err := rec.Replay(logger); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if n := logger.Dropped(); n != 1 {
+		t.Errorf("expected 1 dropped entry, got %d", n)
+	}
+	if n := logger.Counts()[LevelInfo]; n != 0 {
+		t.Errorf("expected no counted entries, got %d", n)
+	}
+}
@@ -14,6 +14,7 @@ import (
 func main() {
 	cfg := blip.DefaultConfig()
 	cfg.Level = blip.LevelDebug
+	cfg.PanicOnPanicLevel = false
 	timeFormat := flag.String("time", "2006-01-02 15:04:05.000", "Time format")
 	color := flag.Bool("color", true, "Colorized output")
 	sort := flag.Bool("sort", true, "Sort fields")
@@ -27,10 +28,11 @@ func main() {
 		cfg.Encoder = enc
 	case "console":
 		cfg.Encoder = &blip.ConsoleEncoder{
-			TimeFormat:      *timeFormat,
-			Color:           *color,
-			SortFields:      *sort,
-			MinMessageWidth: *width,
+			TimeFormat:          *timeFormat,
+			Color:               *color,
+			SortFields:          *sort,
+			MinMessageWidth:     *width,
+			FieldSeparatorWidth: 2,
 		}
 	default:
 		panic("invalid encoder")
@@ -2,21 +2,74 @@ package blip
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base32"
 	"maps"
+	"sync/atomic"
+	"time"
 )
 
 type contextKey struct{}
 
+type levelContextKey struct{}
+
+type startTimeContextKey struct{}
+
+type errorFieldsContextKey struct{}
+
+// levelOverrides is set once a level override is added to a context. Until
+// then, looking up overrides is skipped.
+var levelOverrides atomic.Bool
+
+// startTimes is set once a start time is added to a context. Until then,
+// looking up start times is skipped.
+var startTimes atomic.Bool
+
+// errorFields is set once error fields are added to a context. Until then,
+// looking up error fields is skipped.
+var errorFields atomic.Bool
+
+const traceIDKey = "trace_id"
+
+// TraceIDGenerator generates trace IDs for ContextWithTraceID. By default it
+// returns a base32 encoding of 8 random bytes.
+var TraceIDGenerator = func() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return traceIDEncoding.EncodeToString(b[:])
+}
+
+var traceIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
 // ContextWithFields adds fields to the context. If the context already has
-// fields, it merges the new fields with the existing ones.
+// fields, it merges the new fields with the existing ones into a new field set,
+// leaving the fields of the parent context unchanged.
 func ContextWithFields(ctx context.Context, fields F) context.Context {
-	existing := FieldsFromContext(ctx)
-	if existing == nil {
-		existing = fields
-	} else {
-		maps.Copy(existing, fields)
+	return context.WithValue(ctx, contextKey{}, mergeFields(FieldsFromContext(ctx), fields))
+}
+
+// mergeFields returns a new field set with the fields of both sets, the fields
+// of b taking precedence. If a is nil, b is returned as is.
+func mergeFields(a, b F) F {
+	if a == nil {
+		return b
+	}
+	merged := make(F, len(a)+len(b))
+	maps.Copy(merged, a)
+	maps.Copy(merged, b)
+	return merged
+}
+
+// MergeContextFields returns a copy of dst with the fields of src added to its
+// fields, the fields of src taking precedence, e.g. to log entries about an
+// item with the fields of both the job and the item contexts. Neither context
+// is modified. Only fields are merged, other values come from dst.
+func MergeContextFields(dst, src context.Context) context.Context {
+	fields := FieldsFromContext(src)
+	if len(fields) == 0 {
+		return dst
 	}
-	return context.WithValue(ctx, contextKey{}, existing)
+	return ContextWithFields(dst, fields)
 }
 
 // FieldsFromContext retrieves fields from the context. If no fields are found,
@@ -27,3 +80,84 @@ func FieldsFromContext(ctx context.Context) F {
 	}
 	return nil
 }
+
+// ContextWithErrorFields adds fields to the context that are only logged with
+// entries at the error level or above, e.g. request details that help to debug
+// failures but would bloat every other entry. Like ContextWithFields, it merges
+// the new fields with the existing error fields into a new field set. Regular
+// context fields take precedence over error fields with the same keys.
+func ContextWithErrorFields(ctx context.Context, fields F) context.Context {
+	errorFields.Store(true)
+	return context.WithValue(ctx, errorFieldsContextKey{}, mergeFields(ErrorFieldsFromContext(ctx), fields))
+}
+
+// ErrorFieldsFromContext retrieves the error fields from the context. If no
+// fields are found, it returns nil.
+func ErrorFieldsFromContext(ctx context.Context) F {
+	if !errorFields.Load() {
+		return nil
+	}
+	f, _ := ctx.Value(errorFieldsContextKey{}).(F)
+	return f
+}
+
+// ContextWithTraceID adds a trace ID field to the context, so that all entries
+// logged with it can be correlated. If the context already has a trace ID, it
+// is returned unchanged.
+func ContextWithTraceID(ctx context.Context) context.Context {
+	if TraceIDFromContext(ctx) != "" {
+		return ctx
+	}
+	return ContextWithFields(ctx, F{traceIDKey: TraceIDGenerator()})
+}
+
+// TraceIDFromContext retrieves the trace ID from the context. If no trace ID
+// is found, it returns an empty string.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := FieldsFromContext(ctx)[traceIDKey].(string)
+	return id
+}
+
+// ContextWithLevel overrides the minimum logging level for entries logged with
+// the context. The override can only make logging more verbose: the more
+// verbose of the configured and the context level is used.
+func ContextWithLevel(ctx context.Context, lev Level) context.Context {
+	levelOverrides.Store(true)
+	return context.WithValue(ctx, levelContextKey{}, lev)
+}
+
+// LevelFromContext retrieves the level override from the context. If no
+// override is found, it returns zero.
+func LevelFromContext(ctx context.Context) Level {
+	lev, _ := ctx.Value(levelContextKey{}).(Level)
+	return lev
+}
+
+// levelOverridden reports whether the context overrides the level to enable
+// entries at the given level.
+func levelOverridden(ctx context.Context, lev Level) bool {
+	if !levelOverrides.Load() {
+		return false
+	}
+	override := LevelFromContext(ctx)
+	return override != 0 && override <= lev
+}
+
+// ContextWithStartTime stores the start time of an operation, e.g. a request,
+// in the context. Entries logged with the context get an "elapsed" field, see
+// Config.ElapsedKey, with the time passed since the start truncated to
+// DurationFieldPrecision.
+func ContextWithStartTime(ctx context.Context, start time.Time) context.Context {
+	startTimes.Store(true)
+	return context.WithValue(ctx, startTimeContextKey{}, start)
+}
+
+// StartTimeFromContext retrieves the start time from the context. If no start
+// time is found, it returns the zero time.
+func StartTimeFromContext(ctx context.Context) time.Time {
+	if !startTimes.Load() {
+		return time.Time{}
+	}
+	start, _ := ctx.Value(startTimeContextKey{}).(time.Time)
+	return start
+}
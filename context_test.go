@@ -0,0 +1,251 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextWithTraceID(t *testing.T) {
+	ctx := ContextWithTraceID(context.Background())
+	id := TraceIDFromContext(ctx)
+	if len(id) != 13 {
+		t.Fatalf("expected a 13 character trace ID, got %q", id)
+	}
+
+	if got := TraceIDFromContext(ContextWithTraceID(ctx)); got != id {
+		t.Errorf("expected trace ID to be preserved, got %q and %q", id, got)
+	}
+
+	child := ContextWithFields(ctx, F{"task_id": 123})
+	child = ContextWithTraceID(child)
+	if got := TraceIDFromContext(child); got != id {
+		t.Errorf("expected child context to inherit trace ID %q, got %q", id, got)
+	}
+
+	other := TraceIDFromContext(ContextWithTraceID(context.Background()))
+	if other == id {
+		t.Errorf("expected unrelated contexts to get different trace IDs, got %q twice", id)
+	}
+}
+
+func TestContextWithTraceIDGenerator(t *testing.T) {
+	gen := TraceIDGenerator
+	defer func() { TraceIDGenerator = gen }()
+	TraceIDGenerator = func() string { return "custom" }
+
+	ctx := ContextWithTraceID(context.Background())
+	if id := TraceIDFromContext(ctx); id != "custom" {
+		t.Errorf("expected custom trace ID, got %q", id)
+	}
+}
+
+func TestTraceIDFromContextEmpty(t *testing.T) {
+	if id := TraceIDFromContext(context.Background()); id != "" {
+		t.Errorf("expected empty trace ID, got %q", id)
+	}
+}
+
+func TestContextWithLevel(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Output = &buf
+	cfg.Level = LevelInfo
+	logger := New(cfg)
+
+	ctx := context.Background()
+	verbose := ContextWithLevel(ctx, LevelDebug)
+
+	logger.Debug(ctx, "Not verbose")
+	logger.Trace(verbose, "Too verbose")
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output, got %q", buf.String())
+	}
+	logger.Debug(verbose, "Verbose")
+	if !strings.Contains(buf.String(), "Verbose") {
+		t.Errorf("expected debug entry, got %q", buf.String())
+	}
+
+	// Overrides can't make logging less verbose
+	buf.Reset()
+	logger.Info(ContextWithLevel(ctx, LevelError), "Still logged")
+	if !strings.Contains(buf.String(), "Still logged") {
+		t.Errorf("expected info entry, got %q", buf.String())
+	}
+}
+
+func TestLevelFromContext(t *testing.T) {
+	ctx := context.Background()
+	if lev := LevelFromContext(ctx); lev != 0 {
+		t.Errorf("expected no level, got %v", lev)
+	}
+	if lev := LevelFromContext(ContextWithLevel(ctx, LevelTrace)); lev != LevelTrace {
+		t.Errorf("expected %v, got %v", LevelTrace, lev)
+	}
+}
+
+func TestGroupContextFields(t *testing.T) {
+	ctx := ContextWithFields(context.Background(), F{"request_id": "r1", "user": "bob"})
+	fields := F{"task_id": 1, "user": "alice"}
+
+	tests := []struct {
+		name    string
+		group   bool
+		enc     Encoder
+		exp     string
+		keyNorm func(string) string
+	}{
+		{"console flat", false, &ConsoleEncoder{SortFields: true},
+			"INFO Task started  request_id=r1 task_id=1 user=alice\n", nil},
+		{"console grouped", true, &ConsoleEncoder{SortFields: true},
+			"INFO Task started  ctx={request_id=r1 user=bob} task_id=1 user=alice\n", nil},
+		{"json flat", false, &JSONEncoder{KeyLevel: "level", KeyMessage: "message", SortFields: true},
+			`{"level":"info","message":"Task started","request_id":"r1","task_id":1,"user":"alice"}` + "\n", nil},
+		{"json grouped", true, &JSONEncoder{KeyLevel: "level", KeyMessage: "message", SortFields: true},
+			`{"level":"info","message":"Task started","ctx":{"request_id":"r1","user":"bob"},"task_id":1,"user":"alice"}` + "\n", nil},
+		{"normalized", true, &ConsoleEncoder{SortFields: true},
+			"INFO Task started  CTX={REQUEST_ID=r1 USER=bob} TASK_ID=1 USER=alice\n", strings.ToUpper},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := New(Config{
+			Output:             &buf,
+			Encoder:            tt.enc,
+			GroupContextFields: tt.group,
+			KeyNormalizer:      tt.keyNorm,
+		})
+		logger.Info(ctx, "Task started", fields)
+		if buf.String() != tt.exp {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.exp, buf.String())
+		}
+	}
+
+	// Grouped fields are added like the others and redacted
+	var buf bytes.Buffer
+	logger := New(Config{
+		Output:             &buf,
+		Encoder:            &ConsoleEncoder{SortFields: true},
+		GroupContextFields: true,
+		ValueRedactor:      func(s string) string { return strings.ReplaceAll(s, "bob", "***") },
+	})
+	ctx = ContextWithFields(ctx, Cause(errors.New("no access")))
+	logger.Info(ctx, "Task started")
+	if exp := "INFO Task started  ctx={error=no access request_id=r1 user=***}\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestContextWithStartTime(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	now := start.Add(12*time.Millisecond + 345*time.Microsecond)
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	logger := New(Config{Output: &buf, Encoder: &ConsoleEncoder{}})
+	ctx := ContextWithStartTime(context.Background(), start)
+
+	logger.Info(ctx, "Request handled")
+	logger.Ctx(ctx).Cached().Info("Cached")
+	logger.Info(context.Background(), "No start time")
+	exp := "INFO Request handled  elapsed=12ms\n" +
+		"INFO Cached  elapsed=12ms\n" +
+		"INFO No start time\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+
+	buf.Reset()
+	logger = New(Config{Output: &buf, Encoder: &JSONEncoder{KeyLevel: "level", KeyMessage: "message"}, ElapsedKey: "took"})
+	logger.Info(ctx, "Request handled")
+	if exp := `{"level":"info","message":"Request handled","took":"12ms"}` + "\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+
+	if got := StartTimeFromContext(ctx); !got.Equal(start) {
+		t.Errorf("expected start time %v, got %v", start, got)
+	}
+	if got := StartTimeFromContext(context.Background()); !got.IsZero() {
+		t.Errorf("expected no start time, got %v", got)
+	}
+}
+
+func TestContextWithFieldsCopies(t *testing.T) {
+	parent := ContextWithFields(context.Background(), F{"a": 1})
+	child := ContextWithFields(parent, F{"a": 2, "b": 3})
+	if fields := FieldsFromContext(parent); len(fields) != 1 || fields["a"] != 1 {
+		t.Errorf("expected parent fields to be unchanged, got %v", fields)
+	}
+	if fields := FieldsFromContext(child); len(fields) != 2 || fields["a"] != 2 || fields["b"] != 3 {
+		t.Errorf("expected merged fields, got %v", fields)
+	}
+}
+
+func TestMergeContextFields(t *testing.T) {
+	job := ContextWithFields(context.Background(), F{"job_id": 1, "status": "running"})
+	job = ContextWithLevel(job, LevelDebug)
+	item := ContextWithFields(context.Background(), F{"item_id": 2, "status": "failed"})
+
+	merged := MergeContextFields(job, item)
+	fields := FieldsFromContext(merged)
+	exp := F{"job_id": 1, "item_id": 2, "status": "failed"}
+	if len(fields) != len(exp) {
+		t.Errorf("expected %v, got %v", exp, fields)
+	}
+	for k, v := range exp {
+		if fields[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, fields[k])
+		}
+	}
+	if lev := LevelFromContext(merged); lev != LevelDebug {
+		t.Errorf("expected the level of dst, got %v", lev)
+	}
+
+	// Neither source is modified
+	if fields := FieldsFromContext(job); len(fields) != 2 || fields["status"] != "running" {
+		t.Errorf("expected dst fields to be unchanged, got %v", fields)
+	}
+	if fields := FieldsFromContext(item); len(fields) != 2 || fields["job_id"] != nil {
+		t.Errorf("expected src fields to be unchanged, got %v", fields)
+	}
+
+	// Without fields in src, dst is returned as is
+	if ctx := MergeContextFields(job, context.Background()); ctx != job {
+		t.Error("expected dst to be returned")
+	}
+}
+
+func TestContextWithErrorFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Output: &buf, Encoder: &ConsoleEncoder{SortFields: true}, StackTraceLevel: LevelPanic})
+	ctx := ContextWithFields(context.Background(), F{"request_id": "r1", "user": "alice"})
+	ctx = ContextWithErrorFields(ctx, F{"headers": "a=1", "user": "bob"})
+	ctx = ContextWithErrorFields(ctx, F{"body": "{}"})
+
+	logger.Info(ctx, "Handled")
+	logger.Warn(ctx, "Slow")
+	logger.Error(ctx, "Failed", F{"body": "[]"})
+	logger.Ctx(ctx).Cached().Error("Cached")
+	exp := "INFO Handled  request_id=r1 user=alice\n" +
+		"WARN Slow  request_id=r1 user=alice\n" +
+		"ERRO Failed  body=[] headers=a=1 request_id=r1 user=alice\n" +
+		"ERRO Cached  body={} headers=a=1 request_id=r1 user=alice\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+
+	// Error fields are grouped with the other context fields
+	buf.Reset()
+	logger = New(Config{Output: &buf, Encoder: &ConsoleEncoder{}, StackTraceLevel: LevelPanic, GroupContextFields: true})
+	logger.Error(ContextWithErrorFields(context.Background(), F{"body": "{}"}), "Failed")
+	if exp := "ERRO Failed  ctx={body={}}\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+
+	if fields := ErrorFieldsFromContext(ctx); len(fields) != 3 {
+		t.Errorf("expected 3 error fields, got %v", fields)
+	}
+}
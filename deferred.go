@@ -0,0 +1,64 @@
+package blip
+
+import "io"
+
+// DeferredLogger buffers entries in memory and only logs them with the
+// underlying logger if Flush is called, e.g. to log the debug entries of a
+// request only if it fails:
+//
+//	dl := blip.NewDeferredLogger(logger, blip.LevelDebug, 1000)
+//	if err := handle(ctx, dl.Logger, req); err != nil {
+//		dl.Flush()
+//		return err
+//	}
+//	dl.Commit()
+//
+// Entries are formatted by the encoder of the underlying logger when they are
+// flushed, keeping the time they were logged at. Fatal entries exit before
+// they can be flushed, and panic entries panic as usual.
+type DeferredLogger struct {
+	// Logger buffers the entries. It has the configuration of the underlying
+	// logger, except for the level.
+	*Logger
+
+	target *Logger
+	rec    *Recorder
+}
+
+// NewDeferredLogger creates a deferred logger that buffers entries at the given
+// level or above for the logger l. At most maxEntries entries are buffered,
+// the oldest entries are dropped to make room for new ones; zero means no
+// limit.
+func NewDeferredLogger(l *Logger, lev Level, maxEntries int) *DeferredLogger {
+	rec := &Recorder{MaxEntries: maxEntries}
+	cfg := l.Config()
+	cfg.Level = lev
+	cfg.Levels = nil
+	cfg.LevelSchedule = nil
+	cfg.Encoder = rec
+	cfg.FallbackEncoder = nil
+	cfg.Sampler = nil
+	cfg.Output = io.Discard
+	return &DeferredLogger{
+		Logger: New(cfg),
+		target: l,
+		rec:    rec,
+	}
+}
+
+// Flush logs the buffered entries with the underlying logger, regardless of
+// its level, and empties the buffer. It returns the first error returned by
+// the output of the underlying logger.
+func (d *DeferredLogger) Flush() error {
+	return replay(d.target, d.rec.take(), false)
+}
+
+// Abort is an alias for Flush, for symmetry with Commit.
+func (d *DeferredLogger) Abort() error {
+	return d.Flush()
+}
+
+// Commit discards the buffered entries.
+func (d *DeferredLogger) Commit() {
+	d.rec.Reset()
+}
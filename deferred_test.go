@@ -0,0 +1,65 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDeferredLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Output: &buf, Encoder: &ConsoleEncoder{}, Level: LevelInfo})
+	ctx := context.Background()
+
+	t.Run("commit", func(t *testing.T) {
+		buf.Reset()
+		dl := NewDeferredLogger(logger, LevelDebug, 10)
+		dl.Debug(ctx, "Parsing request")
+		dl.Info(ctx, "Request handled")
+		dl.Commit()
+		if err := dl.Flush(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected no output, got %q", buf.String())
+		}
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		buf.Reset()
+		dl := NewDeferredLogger(logger, LevelDebug, 10)
+		dl.Trace(ctx, "Below the deferred level")
+		dl.Debug(ctx, "Parsing request", F{"size": 42})
+		dl.Warn(ctx, "Request failed")
+		if buf.Len() != 0 {
+			t.Fatalf("expected entries to be buffered, got %q", buf.String())
+		}
+		if err := dl.Abort(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Debug entries are flushed even though the logger is at info level
+		exp := "DEBU Parsing request  size=42\nWARN Request failed\n"
+		if buf.String() != exp {
+			t.Errorf("expected %q, got %q", exp, buf.String())
+		}
+
+		// The buffer is emptied
+		buf.Reset()
+		_ = dl.Flush()
+		if buf.Len() != 0 {
+			t.Errorf("expected no output, got %q", buf.String())
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		buf.Reset()
+		dl := NewDeferredLogger(logger, LevelDebug, 2)
+		for _, msg := range []string{"First", "Second", "Third"} {
+			dl.Info(ctx, msg)
+		}
+		_ = dl.Flush()
+		if exp := "INFO Second\nINFO Third\n"; buf.String() != exp {
+			t.Errorf("expected %q, got %q", exp, buf.String())
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package blip
+
+import "reflect"
+
+// Diff returns a field set describing the keys whose values differ between
+// the field sets before and after a change, e.g. to log updates of an entity.
+// Changes are logged under the "changes" key as an object with the old and new
+// value of every changed key; added keys only have a new value, removed keys
+// only an old one:
+//
+//	changes={status={new=done old=running} retries={new=1}}
+//
+// Unchanged keys are omitted, and so is the whole field if nothing changed.
+// Values are compared with reflect.DeepEqual.
+func Diff(before, after F) F {
+	changes := map[string]any{}
+	for k, ov := range before {
+		nv, ok := after[k]
+		switch {
+		case !ok:
+			changes[k] = map[string]any{"old": ov}
+		case !reflect.DeepEqual(ov, nv):
+			changes[k] = map[string]any{"old": ov, "new": nv}
+		}
+	}
+	for k, nv := range after {
+		if _, ok := before[k]; !ok {
+			changes[k] = map[string]any{"new": nv}
+		}
+	}
+	if len(changes) == 0 {
+		return F{}
+	}
+	return F{"changes": changes}
+}
@@ -0,0 +1,39 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := F{"status": "running", "retries": 0, "tags": []string{"a"}, "owner": "bob"}
+	after := F{"status": "done", "retries": 0, "tags": []string{"a"}, "result": "ok"}
+
+	exp := F{"changes": map[string]any{
+		"status": map[string]any{"old": "running", "new": "done"},
+		"owner":  map[string]any{"old": "bob"},
+		"result": map[string]any{"new": "ok"},
+	}}
+	if got := Diff(before, after); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+	if got := Diff(before, before); len(got) != 0 {
+		t.Errorf("expected no changes, got %v", got)
+	}
+}
+
+func TestDiffLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Output: &buf, Encoder: &ConsoleEncoder{SortFields: true}})
+
+	logger.Info(context.Background(), "Task updated",
+		Diff(F{"status": "running"}, F{"status": "done", "retries": 1}),
+		F{"task_id": 1},
+	)
+	exp := "INFO Task updated  changes={retries={new=1} status={new=done old=running}} task_id=1\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
@@ -0,0 +1,169 @@
+package blip
+
+import (
+	"strconv"
+	"time"
+)
+
+// DurationFormat controls how encoders write time.Duration field values.
+type DurationFormat int
+
+const (
+	// DurationFormatString writes durations the way time.Duration.String
+	// does, e.g. "1h2m3.5s".
+	DurationFormatString DurationFormat = iota
+	// DurationFormatISO writes durations in the ISO 8601 format, e.g.
+	// "PT1H2M3.5S", see FormatISODuration.
+	DurationFormatISO
+	// DurationFormatHuman writes durations in a single unit below a minute and
+	// as space separated components above, e.g. "250ms", "1.5s", and
+	// "2m 3s", see FormatHumanDuration. It is meant for reading in a
+	// terminal, the spaces make the console output harder to parse.
+	DurationFormatHuman
+)
+
+// writeDuration writes a duration field value, truncated to
+// DurationFieldPrecision, in the given format.
+func writeDuration(buf *Buffer, d time.Duration, format DurationFormat) {
+	d = d.Truncate(DurationFieldPrecision)
+	switch format {
+	case DurationFormatISO:
+		buf.WriteISODuration(d)
+	case DurationFormatHuman:
+		buf.WriteHumanDuration(d)
+	default:
+		buf.WriteDuration(d)
+	}
+}
+
+// FormatISODuration formats a duration in the ISO 8601 format, e.g.
+// "PT1H2M3.5S". Durations are expressed in hours, minutes, and seconds, as
+// days and longer units vary in length. Fractions of a second are written with
+// up to nine digits, zero is written as "PT0S", and negative durations are
+// prefixed with a minus sign.
+func FormatISODuration(d time.Duration) string {
+	return string(appendISODuration(nil, d))
+}
+
+// WriteISODuration writes a time.Duration value to the buffer in the ISO 8601
+// format, see FormatISODuration.
+func (buf *Buffer) WriteISODuration(d time.Duration) {
+	buf.b = appendISODuration(buf.b, d)
+}
+
+func appendISODuration(b []byte, d time.Duration) []byte {
+	u := uint64(d)
+	if d < 0 {
+		b = append(b, '-')
+		u = -u
+	}
+	b = append(b, 'P', 'T')
+	if u == 0 {
+		return append(b, '0', 'S')
+	}
+
+	h := u / uint64(time.Hour)
+	u -= h * uint64(time.Hour)
+	m := u / uint64(time.Minute)
+	u -= m * uint64(time.Minute)
+	s := u / uint64(time.Second)
+	ns := u - s*uint64(time.Second)
+	if h > 0 {
+		b = strconv.AppendUint(b, h, 10)
+		b = append(b, 'H')
+	}
+	if m > 0 {
+		b = strconv.AppendUint(b, m, 10)
+		b = append(b, 'M')
+	}
+	if s > 0 || ns > 0 {
+		b = strconv.AppendUint(b, s, 10)
+		if ns > 0 {
+			var frac [10]byte
+			frac[0] = '.'
+			for i := 9; i > 0; i-- {
+				frac[i] = byte('0' + ns%10)
+				ns /= 10
+			}
+			n := 10
+			for frac[n-1] == '0' {
+				n--
+			}
+			b = append(b, frac[:n]...)
+		}
+		b = append(b, 'S')
+	}
+	return b
+}
+
+// FormatHumanDuration formats a duration for reading, e.g. "250ms", "1.5s", or
+// "1h 2m 3s". Durations under a minute are written in the largest unit they
+// have one of, from nanoseconds to seconds, with fractions of the unit if
+// any. Longer durations are written in hours, minutes, and seconds, omitting
+// zero components. Zero is written as "0s".
+func FormatHumanDuration(d time.Duration) string {
+	return string(appendHumanDuration(nil, d))
+}
+
+// WriteHumanDuration writes a time.Duration value to the buffer in a human
+// readable format, see FormatHumanDuration.
+func (buf *Buffer) WriteHumanDuration(d time.Duration) {
+	buf.b = appendHumanDuration(buf.b, d)
+}
+
+func appendHumanDuration(b []byte, d time.Duration) []byte {
+	u := uint64(d)
+	if d < 0 {
+		b = append(b, '-')
+		u = -u
+	}
+	switch {
+	case u == 0:
+		return append(b, '0', 's')
+	case u < uint64(time.Microsecond):
+		return append(strconv.AppendUint(b, u, 10), 'n', 's')
+	case u < uint64(time.Millisecond):
+		return appendDurationUnit(b, u, uint64(time.Microsecond), "µs")
+	case u < uint64(time.Second):
+		return appendDurationUnit(b, u, uint64(time.Millisecond), "ms")
+	case u < uint64(time.Minute):
+		return appendDurationUnit(b, u, uint64(time.Second), "s")
+	}
+
+	h := u / uint64(time.Hour)
+	u -= h * uint64(time.Hour)
+	m := u / uint64(time.Minute)
+	u -= m * uint64(time.Minute)
+	if h > 0 {
+		b = strconv.AppendUint(b, h, 10)
+		b = append(b, 'h')
+	}
+	if m > 0 {
+		if h > 0 {
+			b = append(b, ' ')
+		}
+		b = strconv.AppendUint(b, m, 10)
+		b = append(b, 'm')
+	}
+	if u > 0 {
+		b = append(b, ' ')
+		b = appendDurationUnit(b, u, uint64(time.Second), "s")
+	}
+	return b
+}
+
+// appendDurationUnit appends v nanoseconds in the given unit, with the
+// fraction of the unit trimmed of trailing zeros.
+func appendDurationUnit(b []byte, v, unit uint64, suffix string) []byte {
+	b = strconv.AppendUint(b, v/unit, 10)
+	if frac := v % unit; frac > 0 {
+		b = append(b, '.')
+		for div := unit / 10; div > 0; div /= 10 {
+			b = append(b, byte('0'+frac/div%10))
+		}
+		for b[len(b)-1] == '0' {
+			b = b[:len(b)-1]
+		}
+	}
+	return append(b, suffix...)
+}
@@ -0,0 +1,87 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFormatISODuration(t *testing.T) {
+	tests := []struct {
+		d   time.Duration
+		exp string
+	}{
+		{0, "PT0S"},
+		{time.Nanosecond, "PT0.000000001S"},
+		{250 * time.Millisecond, "PT0.25S"},
+		{3*time.Second + 500*time.Millisecond, "PT3.5S"},
+		{2 * time.Minute, "PT2M"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "PT1H2M3S"},
+		{49*time.Hour + 30*time.Second, "PT49H30S"},
+		{-1500 * time.Millisecond, "-PT1.5S"},
+		{math.MinInt64, "-PT2562047H47M16.854775808S"},
+	}
+	for _, tt := range tests {
+		if got := FormatISODuration(tt.d); got != tt.exp {
+			t.Errorf("%v: expected %q, got %q", tt.d, tt.exp, got)
+		}
+	}
+}
+
+func TestDurationFormat(t *testing.T) {
+	fields := F{"took": time.Hour + 2*time.Minute + 3500*time.Millisecond}
+	tests := []struct {
+		enc Encoder
+		exp string
+	}{
+		{&ConsoleEncoder{}, "INFO Done  took=1h2m3.5s\n"},
+		{&ConsoleEncoder{DurationFormat: DurationFormatISO}, "INFO Done  took=PT1H2M3.5S\n"},
+		{&ConsoleEncoder{DurationFormat: DurationFormatHuman}, "INFO Done  took=1h 2m 3.5s\n"},
+		{&JSONEncoder{KeyMessage: "message", DurationFormat: DurationFormatISO, Order: []string{"message"}},
+			`{"message":"Done","took":"PT1H2M3.5S"}` + "\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := New(Config{Output: &buf, Encoder: tt.enc})
+		logger.Info(context.Background(), "Done", fields)
+		if buf.String() != tt.exp {
+			t.Errorf("expected %q, got %q", tt.exp, buf.String())
+		}
+	}
+}
+
+func TestFormatHumanDuration(t *testing.T) {
+	tests := []struct {
+		d   time.Duration
+		exp string
+	}{
+		{0, "0s"},
+		{750 * time.Nanosecond, "750ns"},
+		{1500 * time.Nanosecond, "1.5µs"},
+		{250 * time.Millisecond, "250ms"},
+		{1234567 * time.Nanosecond, "1.234567ms"},
+		{1500 * time.Millisecond, "1.5s"},
+		{59 * time.Second, "59s"},
+		{2 * time.Minute, "2m"},
+		{2*time.Minute + 3*time.Second, "2m 3s"},
+		{2*time.Minute + 3500*time.Millisecond, "2m 3.5s"},
+		{time.Hour + 5*time.Second, "1h 5s"},
+		{26*time.Hour + 3*time.Minute, "26h 3m"},
+		{-1500 * time.Millisecond, "-1.5s"},
+	}
+	for _, tt := range tests {
+		if got := FormatHumanDuration(tt.d); got != tt.exp {
+			t.Errorf("%v: expected %q, got %q", tt.d, tt.exp, got)
+		}
+	}
+
+	// Encoders apply DurationFieldPrecision
+	var buf bytes.Buffer
+	logger := New(Config{Output: &buf, Encoder: &ConsoleEncoder{DurationFormat: DurationFormatHuman}})
+	logger.Info(context.Background(), "Done", F{"took": 1234567 * time.Nanosecond})
+	if exp := "INFO Done  took=1ms\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
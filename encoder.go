@@ -1,5 +1,13 @@
 package blip
 
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
 // Encoder is an interface for encoding log messages.
 type Encoder interface {
 	// Start writes the beginning of the log message.
@@ -12,8 +20,144 @@ type Encoder interface {
 	EncodeMessage(buf *Buffer, msg string)
 	// EncodeFields encodes the fields of the log message.
 	EncodeFields(buf *Buffer, lev Level, fields *[]Field)
-	// EncodeStackTrace encodes the stack trace of the log message.
+	// EncodeStackTrace encodes the stack trace of the log message, skipping
+	// the given number of frames, 0 starting at EncodeStackTrace itself. See
+	// StackTrace.
 	EncodeStackTrace(buf *Buffer, skip int)
 	// End writes the end of the log message.
 	End(buf *Buffer)
 }
+
+// Entry is a log entry passed to encoders implementing EntryEncoder.
+type Entry struct {
+	// Time is when the entry was logged. It is captured once per entry, so
+	// the fallback encoder gets the same time as the primary one.
+	Time  time.Time
+	Level Level
+	// Name is the name of the logger, see Config.Name.
+	Name    string
+	Message string
+	// Fields holds the fields of the entry, it may be nil.
+	Fields *[]Field
+	// StackTrace is the formatted stack trace, or empty if the entry has none.
+	// It is formatted as function names followed by file paths and line
+	// numbers indented with a tab, each on its own line.
+	StackTrace string
+
+	// stackPC is the program counter of the first frame of the stack trace,
+	// see EncodeEntry.
+	stackPC uintptr
+}
+
+// EntryEncoder is an encoder that encodes whole entries at once. When the
+// encoder of a logger implements it, Encode is called instead of the methods
+// of the Encoder interface. This lets the encoder control the order of the
+// components and makes new entry data available without changing the
+// interface. Use EncodeEntry to fall back to the Encoder methods.
+type EntryEncoder interface {
+	Encoder
+	Encode(buf *Buffer, e Entry)
+}
+
+// Encode encodes an entry with the encoder the way loggers do: with its Encode
+// method if it implements EntryEncoder, or with the methods of the Encoder
+// interface otherwise. Encoders wrapping other encoders use it to encode
+// entries with them.
+func Encode(enc Encoder, buf *Buffer, e Entry) {
+	switch enc := enc.(type) {
+	// Not EntryEncoders, so that types embedding them and overriding some of
+	// their methods are encoded with the overrides
+	case *ConsoleEncoder:
+		enc.encode(buf, e)
+	case *JSONEncoder:
+		enc.encode(buf, e)
+	case EntryEncoder:
+		enc.Encode(buf, e)
+	default:
+		EncodeEntry(enc, buf, e)
+	}
+}
+
+// EncodeEntry encodes an entry with the methods of the Encoder interface,
+// calling them in the order the logger does. EncodeTime reads the clock
+// itself, and EncodeStackTrace is given the number of frames to skip to start
+// the stack trace where the one of the entry starts. Stack traces of entries
+// encoded outside of the call that logged them, e.g. replayed by a Recorder,
+// are omitted. Encoders that need the time the entry was logged at or its
+// stack trace implement EntryEncoder.
+func EncodeEntry(enc Encoder, buf *Buffer, e Entry) {
+	enc.Start(buf)
+	enc.EncodeTime(buf)
+	enc.EncodeLevel(buf, e.Level)
+	enc.EncodeMessage(buf, e.Message)
+	enc.EncodeFields(buf, e.Level, e.Fields)
+	if e.StackTrace != "" {
+		if skip, ok := stackSkip(e.stackPC); ok {
+			enc.EncodeStackTrace(buf, skip)
+		}
+	}
+	enc.End(buf)
+}
+
+// stackSkip returns the number of frames EncodeStackTrace skips to start the
+// stack trace at the frame with the given program counter when it is called
+// by EncodeEntry. It reports false if the frame is not on the stack.
+func stackSkip(pc uintptr) (int, bool) {
+	if pc == 0 {
+		return 0, false
+	}
+	var pcs [64]uintptr
+	// Skip runtime.Callers and stackSkip, starting at EncodeEntry
+	n := runtime.Callers(2, pcs[:])
+	for i, p := range pcs[:n] {
+		if p == pc {
+			// Frame 0 is EncodeStackTrace, called by EncodeEntry
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]func() Encoder{
+		"console": func() Encoder { return NewConsoleEncoder() },
+		"json":    func() Encoder { return NewJSONEncoder() },
+	}
+)
+
+// RegisterEncoder makes an encoder available by name to NewEncoderByName, e.g.
+// to select it in a configuration file. The factory is called to create a new
+// encoder each time one is requested. Names are case-insensitive, registering
+// an encoder under an existing name replaces it. The "console" and "json"
+// names are registered by default.
+func RegisterEncoder(name string, factory func() Encoder) {
+	if factory == nil {
+		panic("blip: RegisterEncoder factory is nil")
+	}
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[strings.ToLower(name)] = factory
+}
+
+// NewEncoderByName creates an encoder registered with RegisterEncoder. The
+// name is case-insensitive.
+func NewEncoderByName(name string) (Encoder, error) {
+	encodersMu.RLock()
+	factory, ok := encoders[strings.ToLower(name)]
+	encodersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown encoder: %q", name)
+	}
+	return factory(), nil
+}
+
+// writeLineEnding writes the line ending terminating an entry, falling back to
+// a newline if none is configured.
+func writeLineEnding(buf *Buffer, le []byte) {
+	if le == nil {
+		buf.WriteBytes('\n')
+		return
+	}
+	buf.WriteBytes(le...)
+}
@@ -1,34 +1,170 @@
 package blip
 
 import (
+	"bytes"
 	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // ConsoleEncoder is a console encoder that formats log messages in a
 // human-readable format.
 type ConsoleEncoder struct {
-	TimeFormat      string
-	TimePrecision   time.Duration
+	TimeFormat    string
+	TimePrecision time.Duration
+	// Location is the time zone of timestamps. Defaults to local time.
+	Location        *time.Location
 	MinMessageWidth int
-	SortFields      bool
-	Color           bool
+	// MessageWidthBytes measures message width in bytes instead of terminal
+	// columns when padding messages. It is faster but misaligns fields of
+	// messages with multibyte characters.
+	MessageWidthBytes bool
+	// WrapWidth wraps messages wider than it at spaces into lines of at most
+	// WrapWidth columns, words wider than that are not broken. Continuation
+	// lines are indented to the column of the message and fields follow on
+	// their own line. Messages containing newlines are indented the same way,
+	// blank lines and runs of spaces are kept. Zero disables wrapping. Fields
+	// are only moved to their own line when entries are encoded with Encode,
+	// as loggers do.
+	WrapWidth int
+	// FieldSeparatorWidth is the number of spaces between the message and the
+	// fields. Zero means the default of 2.
+	FieldSeparatorWidth int
+	// KeyValueSep is written between the key and the value of fields, e.g.
+	// ": ". Defaults to "=".
+	KeyValueSep string
+	// FieldSep is written between fields, e.g. " | ". Defaults to a space.
+	FieldSep   string
+	SortFields bool
+	// BoolAsFlag writes true bool fields as the bare key, e.g. "cached"
+	// instead of "cached=true", and omits false ones.
+	BoolAsFlag bool
+	Color      bool
+	// DurationFormat controls how duration fields are written, as
+	// "1h2m3.5s" (default), in the ISO 8601 format, e.g. "PT1H2M3.5S", or in
+	// a human readable format, e.g. "1h 2m 3.5s".
+	DurationFormat DurationFormat
+	// ColorMode selects the color palette used when Color is enabled.
+	ColorMode ColorMode
+	// LineEnding is written after each entry. Defaults to a newline.
+	LineEnding []byte
+	// OmitNewline omits the line ending, for outputs that delimit entries
+	// themselves. The logger writes every entry with a single Write call.
+	OmitNewline bool
+	// RelativeTime replaces the timestamp with the time elapsed since
+	// StartTime, e.g. "+12.3ms". The elapsed time is truncated to
+	// TimePrecision.
+	RelativeTime bool
+	// StartTime is the reference for relative timestamps. When zero, the
+	// logger sets it to the time it was created.
+	StartTime time.Time
+	// TimePosition controls where the timestamp is written: at the start of
+	// the entry (default), at the end of the line after the fields, or not at
+	// all. TimeFormatClock makes a compact timestamp for narrow terminals.
+	TimePosition TimePosition
+	// NilValue is written for nil field values, including nil pointers, maps,
+	// and slices of other types than the ones handled natively. Defaults to
+	// "null" to match the JSON encoder, set it to "<nil>" to match fmt.
+	NilValue string
+	// StackTraceMaxFrames limits the number of frames in stack traces written
+	// by EncodeStackTrace. When zero, the logger sets it to
+	// Config.StackTraceMaxFrames when it is created.
+	StackTraceMaxFrames int
 
-	timeCache func(time.Time) string
+	timeCache timeCache
 }
 
+// ColorMode selects the escape sequences used by the console encoder to
+// colorize output.
+type ColorMode int
+
+const (
+	// ColorModeBasic uses the 16 basic terminal colors.
+	ColorModeBasic ColorMode = iota
+	// ColorMode256 uses the 256 color palette.
+	ColorMode256
+	// ColorModeTrueColor uses 24-bit colors.
+	ColorModeTrueColor
+	// ColorModeAuto picks the richest mode supported by the terminal according
+	// to the TERM and COLORTERM environment variables.
+	ColorModeAuto
+)
+
+const (
+	fontBold  = "\033[1m"
+	fontReset = "\033[0m"
+)
+
+// palette holds the escape sequences used to colorize each level.
+type palette [LevelFatal + 1]string
+
+var (
+	paletteBasic = palette{
+		LevelTrace: "\033[37m",
+		LevelDebug: "\033[37m",
+		LevelInfo:  "\033[36m",
+		LevelWarn:  "\033[33m",
+		LevelError: "\033[31m",
+		LevelPanic: "\033[41m\033[97m",
+		LevelFatal: "\033[41m\033[97m",
+	}
+	palette256 = palette{
+		LevelTrace: "\033[38;5;250m",
+		LevelDebug: "\033[38;5;250m",
+		LevelInfo:  "\033[38;5;80m",
+		LevelWarn:  "\033[38;5;220m",
+		LevelError: "\033[38;5;203m",
+		LevelPanic: "\033[48;5;88m\033[38;5;255m",
+		LevelFatal: "\033[48;5;88m\033[38;5;255m",
+	}
+	paletteTrueColor = palette{
+		LevelTrace: "\033[38;2;180;180;180m",
+		LevelDebug: "\033[38;2;180;180;180m",
+		LevelInfo:  "\033[38;2;86;182;194m",
+		LevelWarn:  "\033[38;2;229;192;123m",
+		LevelError: "\033[38;2;224;108;117m",
+		LevelPanic: "\033[48;2;136;0;0m\033[38;2;255;255;255m",
+		LevelFatal: "\033[48;2;136;0;0m\033[38;2;255;255;255m",
+	}
+)
+
+// detectedColorMode is the color mode supported by the terminal. Environment
+// variables are only read once.
+var detectedColorMode = sync.OnceValue(func() ColorMode {
+	return colorModeFromEnv(os.Getenv("TERM"), os.Getenv("COLORTERM"))
+})
+
+func colorModeFromEnv(term, colorterm string) ColorMode {
+	switch {
+	case colorterm == "truecolor" || colorterm == "24bit":
+		return ColorModeTrueColor
+	case strings.Contains(term, "256color"):
+		return ColorMode256
+	default:
+		return ColorModeBasic
+	}
+}
+
+// TimePosition is the position of the timestamp in console entries.
+type TimePosition int
+
 const (
-	colorRed      = "\033[31m"
-	colorGreen    = "\033[32m"
-	colorYellow   = "\033[33m"
-	colorBlue     = "\033[34m"
-	colorPurple   = "\033[35m"
-	colorCyan     = "\033[36m"
-	colorOffWhite = "\033[37m"
-	colorRedBg    = "\033[48;5;88m"
-	colorWhite    = "\033[38;5;255m"
-	fontBold      = "\033[1m"
-	fontReset     = "\033[0m"
+	// TimePositionStart writes the timestamp at the start of the entry.
+	TimePositionStart TimePosition = iota
+	// TimePositionEnd writes the timestamp at the end of the line, after the
+	// fields. Entries without fields have the timestamp in place of the
+	// fields, so it lines up with padded messages. It needs the whole entry,
+	// so it only applies when entries are encoded with Encode, as loggers do,
+	// and not to types embedding the encoder.
+	TimePositionEnd
+	// TimePositionNone omits the timestamp.
+	TimePositionNone
 )
 
 var _ Encoder = (*ConsoleEncoder)(nil)
@@ -39,31 +175,65 @@ var _ Encoder = (*ConsoleEncoder)(nil)
 // The encoder also supports a minimum message width for padding.
 func NewConsoleEncoder() *ConsoleEncoder {
 	return &ConsoleEncoder{
-		TimeFormat:      defaultTimeFormat,
-		TimePrecision:   defaultTimePrecision,
-		MinMessageWidth: defaultMessageWidth,
-		SortFields:      true,
-		Color:           true,
+		TimeFormat:          defaultTimeFormat,
+		TimePrecision:       defaultTimePrecision,
+		MinMessageWidth:     defaultMessageWidth,
+		FieldSeparatorWidth: defaultFieldSeparatorWidth,
+		SortFields:          true,
+		Color:               true,
+		LineEnding:          []byte{'\n'},
 	}
 }
 
+// Clone returns a copy of the encoder that shares no state with it.
+func (e *ConsoleEncoder) Clone() Encoder {
+	c := &ConsoleEncoder{}
+	*c = *e
+	c.LineEnding = slices.Clone(e.LineEnding)
+	c.timeCache = timeCache{}
+	return c
+}
+
 // Start writes the beginning of the log message.
 func (e *ConsoleEncoder) Start(_ *Buffer) {}
 
-// EncodeTime encodes the time of the log message.
+// EncodeTime encodes the time of the log message. The time is only written
+// here at the start of the entry, see TimePosition.
 func (e *ConsoleEncoder) EncodeTime(buf *Buffer) {
-	if e.TimeFormat == "" {
+	e.encodeTime(buf, timeNow())
+}
+
+func (e *ConsoleEncoder) encodeTime(buf *Buffer, t time.Time) {
+	if e.TimePosition != TimePositionStart || !e.hasTime() {
 		return
 	}
-	if e.TimePrecision > 0 {
-		if e.timeCache == nil {
-			e.timeCache = timeCache(e.TimeFormat, e.TimePrecision)
+	e.writeTime(buf, t)
+	buf.WriteBytes(' ')
+}
+
+// encode encodes a whole entry with the time it was logged at. The time is
+// written after the fields when TimePosition is TimePositionEnd.
+func (e *ConsoleEncoder) encode(buf *Buffer, entry Entry) {
+	e.Start(buf)
+	e.encodeTime(buf, entry.Time)
+	e.EncodeLevel(buf, entry.Level)
+	indent, wrapped := e.encodeMessage(buf, entry.Message)
+	e.encodeFields(buf, entry.Level, entry.Fields, indent, wrapped)
+	if e.TimePosition == TimePositionEnd && e.hasTime() {
+		if !e.hasFields(entry.Fields) {
+			// Write the time where the fields would start
+			for range e.fieldSeparatorWidth() {
+				buf.WriteBytes(' ')
+			}
+		} else {
+			buf.WriteBytes(' ')
 		}
-		buf.WriteString(e.timeCache(timeNow()))
-	} else {
-		buf.WriteTime(timeNow(), e.TimeFormat)
+		e.writeTime(buf, entry.Time)
 	}
-	buf.WriteBytes(' ')
+	if entry.StackTrace != "" {
+		e.writeStackTrace(buf, entry.StackTrace)
+	}
+	e.End(buf)
 }
 
 // EncodeLevel encodes the log level of the message.
@@ -74,60 +244,167 @@ func (e *ConsoleEncoder) EncodeLevel(buf *Buffer, lev Level) {
 
 // EncodeMessage encodes the log message.
 func (e *ConsoleEncoder) EncodeMessage(buf *Buffer, msg string) {
-	if e.Color {
-		buf.WriteString(fontBold)
+	e.encodeMessage(buf, msg)
+}
+
+// encodeMessage encodes the log message and reports whether it was wrapped,
+// along with the indentation of its continuation lines.
+func (e *ConsoleEncoder) encodeMessage(buf *Buffer, msg string) (indent int, wrapped bool) {
+	// Fast path for plain messages
+	if !e.Color && e.MinMessageWidth == 0 && e.WrapWidth <= 0 {
+		buf.WriteString(msg)
+		return 0, false
+	}
+	if e.WrapWidth > 0 && (strings.IndexByte(msg, '\n') >= 0 || e.width(msg) > e.WrapWidth) {
+		return e.writeWrapped(buf, msg)
 	}
-	buf.WriteString(msg)
 	if e.Color {
+		buf.Grow(len(msg) + e.MinMessageWidth + len(fontBold) + len(fontReset))
+		buf.WriteString(fontBold)
+		buf.WriteString(msg)
 		buf.WriteString(fontReset)
+	} else {
+		buf.Grow(len(msg) + e.MinMessageWidth)
+		buf.WriteString(msg)
 	}
-	if e.MinMessageWidth == 0 {
-		return
-	}
-
-	// Pad the message to the configured width +2 spaces to separate it from
-	// the fields.
-	for range e.MinMessageWidth + 2 - len(msg) {
+	for range e.messagePadding(msg) {
 		buf.WriteBytes(' ')
 	}
-	// If the message is long enough not to be padded, add an extra space to
-	// separate it from the fields
-	if len(msg) > e.MinMessageWidth {
-		// Separate message from fields with 2 spaces
-		buf.WriteBytes(' ', ' ')
-	}
+	return 0, false
 }
 
 // EncodeFields encodes the fields of the log message.
 func (e *ConsoleEncoder) EncodeFields(buf *Buffer, lev Level, fields *[]Field) {
-	if fields == nil || len(*fields) == 0 {
+	e.encodeFields(buf, lev, fields, 0, false)
+}
+
+// encodeFields encodes the fields of the log message, on their own line
+// indented by indent if the message was wrapped.
+func (e *ConsoleEncoder) encodeFields(buf *Buffer, lev Level, fields *[]Field, indent int, wrapped bool) {
+	if !e.hasFields(fields) {
 		return
 	}
 	if e.SortFields {
 		sortFields(*fields)
 	}
 
-	// Pad fields with two spaces
-	buf.WriteBytes(' ', ' ')
-	for i, f := range *fields {
-		if i > 0 {
+	if wrapped {
+		buf.WriteBytes('\n')
+		for range indent {
+			buf.WriteBytes(' ')
+		}
+	} else {
+		for range e.fieldSeparatorWidth() {
 			buf.WriteBytes(' ')
 		}
-		e.writeColorized(buf, lev, f.Key)
-		buf.WriteBytes('=')
+	}
+	var n int
+	for _, f := range *fields {
+		flag, isBool := f.Value.(bool)
+		if e.BoolAsFlag && isBool && !flag {
+			continue
+		}
+		if n > 0 {
+			writeSep(buf, e.FieldSep, ' ')
+		}
+		n++
+		e.writeKey(buf, lev, f.Key)
+		if e.BoolAsFlag && isBool {
+			continue
+		}
+		writeSep(buf, e.KeyValueSep, '=')
 		e.writeAny(buf, f.Value)
 	}
 }
 
+// writeKey writes a field key. Keys that would make the output ambiguous are
+// quoted and escaped like JSON strings, e.g. "task id"=1, others are written
+// as they are.
+func (e *ConsoleEncoder) writeKey(buf *Buffer, lev Level, key string) {
+	if !e.keyNeedsQuoting(key) {
+		e.writeColorized(buf, lev, key)
+		return
+	}
+	if e.Color {
+		buf.WriteString(e.palette()[lev])
+	}
+	buf.WriteEscapedString(key)
+	if e.Color {
+		buf.WriteString(fontReset)
+	}
+}
+
+// writeNestedKey writes a key of a nested map, quoting it like writeKey does.
+func (e *ConsoleEncoder) writeNestedKey(buf *Buffer, key string) {
+	if e.keyNeedsQuoting(key) {
+		buf.WriteEscapedString(key)
+		return
+	}
+	buf.WriteString(key)
+}
+
+// keyNeedsQuoting reports whether a key is empty or contains spaces, control
+// characters, quotes, equal signs, or one of the configured separators.
+func (e *ConsoleEncoder) keyNeedsQuoting(key string) bool {
+	if key == "" {
+		return true
+	}
+	for i := range len(key) {
+		if b := key[i]; b <= ' ' || b == '=' || b == '"' || b == 0x7f {
+			return true
+		}
+	}
+	return (e.KeyValueSep != "" && strings.Contains(key, e.KeyValueSep)) ||
+		(e.FieldSep != "" && strings.Contains(key, e.FieldSep))
+}
+
+// writeSep writes a separator, or the default if it is empty.
+func writeSep(buf *Buffer, sep string, def byte) {
+	if sep == "" {
+		buf.WriteBytes(def)
+		return
+	}
+	buf.WriteString(sep)
+}
+
+// hasFields reports whether any of the fields is written.
+func (e *ConsoleEncoder) hasFields(fields *[]Field) bool {
+	if fields == nil {
+		return false
+	}
+	if !e.BoolAsFlag {
+		return len(*fields) > 0
+	}
+	for _, f := range *fields {
+		if flag, ok := f.Value.(bool); !ok || flag {
+			return true
+		}
+	}
+	return false
+}
+
 // EncodeStackTrace encodes the stack trace of the log message.
 func (e *ConsoleEncoder) EncodeStackTrace(buf *Buffer, skip int) {
+	e.writeStackTrace(buf, stackTrace(skip+1, maxFrames(e.StackTraceMaxFrames)))
+}
+
+func (e *ConsoleEncoder) setStackTraceMaxFrames(n int) {
+	if e.StackTraceMaxFrames == 0 {
+		e.StackTraceMaxFrames = n
+	}
+}
+
+func (e *ConsoleEncoder) writeStackTrace(buf *Buffer, trace string) {
+	buf.Grow(len(trace) + 1)
 	buf.WriteBytes('\n')
-	buf.WriteString(stackTrace(skip))
+	buf.WriteString(trace)
 }
 
 // End writes the end of the log message.
 func (e *ConsoleEncoder) End(buf *Buffer) {
-	buf.WriteBytes('\n')
+	if !e.OmitNewline {
+		writeLineEnding(buf, e.LineEnding)
+	}
 }
 
 // WriteAny writes a value of any type to the buffer. It handles various types
@@ -140,6 +417,8 @@ func (e *ConsoleEncoder) writeAny(buf *Buffer, val any) {
 		buf.WriteString(v)
 	case []byte:
 		buf.WriteBytes(v...)
+	case nil:
+		e.writeNil(buf)
 	case int:
 		buf.WriteInt(int64(v))
 	case int8:
@@ -167,38 +446,266 @@ func (e *ConsoleEncoder) writeAny(buf *Buffer, val any) {
 	case bool:
 		buf.WriteBool(v)
 	case time.Duration:
-		buf.WriteDuration(v.Truncate(DurationFieldPrecision))
+		writeDuration(buf, v, e.DurationFormat)
 	case time.Time:
 		buf.WriteTime(v, TimeFieldFormat)
+	case LogValuer:
+		if isNil(v) {
+			e.writeNil(buf)
+			return
+		}
+		e.writeAny(buf, ResolveLogValue(v))
+	case error:
+		if isNil(v) {
+			e.writeNil(buf)
+			return
+		}
+		buf.WriteString(v.Error())
+	case []string:
+		buf.WriteBytes('[')
+		for i, s := range v {
+			if i > 0 {
+				buf.WriteBytes(' ')
+			}
+			buf.WriteString(s)
+		}
+		buf.WriteBytes(']')
+	case []int:
+		buf.WriteBytes('[')
+		for i, n := range v {
+			if i > 0 {
+				buf.WriteBytes(' ')
+			}
+			buf.WriteInt(int64(n))
+		}
+		buf.WriteBytes(']')
+	case []int64:
+		buf.WriteBytes('[')
+		for i, n := range v {
+			if i > 0 {
+				buf.WriteBytes(' ')
+			}
+			buf.WriteInt(n)
+		}
+		buf.WriteBytes(']')
+	case []float64:
+		buf.WriteBytes('[')
+		for i, f := range v {
+			if i > 0 {
+				buf.WriteBytes(' ')
+			}
+			buf.WriteFloat(f, 64)
+		}
+		buf.WriteBytes(']')
+	case map[string]string:
+		buf.WriteBytes('{')
+		for i, k := range sortedKeys(v) {
+			if i > 0 {
+				buf.WriteBytes(' ')
+			}
+			e.writeNestedKey(buf, k)
+			buf.WriteBytes('=')
+			buf.WriteString(v[k])
+		}
+		buf.WriteBytes('}')
+	case map[string]any:
+		buf.WriteBytes('{')
+		for i, k := range sortedKeys(v) {
+			if i > 0 {
+				buf.WriteBytes(' ')
+			}
+			e.writeNestedKey(buf, k)
+			buf.WriteBytes('=')
+			e.writeAny(buf, v[k])
+		}
+		buf.WriteBytes('}')
+	case fmt.Stringer:
+		if isNil(v) {
+			e.writeNil(buf)
+			return
+		}
+		buf.WriteString(v.String())
 	default:
+		if isNil(v) {
+			e.writeNil(buf)
+			return
+		}
 		// TODO: Add support for custom encoders
 		buf.WriteString(fmt.Sprint(v))
 	}
 }
 
+func (e *ConsoleEncoder) writeNil(buf *Buffer) {
+	if e.NilValue == "" {
+		buf.WriteString(defaultNilValue)
+	} else {
+		buf.WriteString(e.NilValue)
+	}
+}
+
+// isNil reports whether a value is a typed nil, e.g. a nil pointer.
+func isNil(v any) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface,
+		reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// hasTime reports whether a timestamp is written.
+func (e *ConsoleEncoder) hasTime() bool {
+	return e.RelativeTime || e.TimeFormat != ""
+}
+
+// fieldSeparatorWidth returns FieldSeparatorWidth, or its default if unset.
+func (e *ConsoleEncoder) fieldSeparatorWidth() int {
+	if e.FieldSeparatorWidth <= 0 {
+		return defaultFieldSeparatorWidth
+	}
+	return e.FieldSeparatorWidth
+}
+
+// writeTime writes the timestamp, or the relative time if RelativeTime is set.
+func (e *ConsoleEncoder) writeTime(buf *Buffer, t time.Time) {
+	if e.RelativeTime {
+		d := t.Sub(e.StartTime)
+		if e.TimePrecision > 0 {
+			d = d.Truncate(e.TimePrecision)
+		}
+		buf.WriteBytes('+')
+		buf.WriteDuration(d)
+		return
+	}
+	if e.TimePrecision > 0 {
+		buf.WriteString(e.timeCache.format(inLocation(t, e.Location), e.TimeFormat, e.TimePrecision))
+	} else {
+		buf.WriteTime(inLocation(t, e.Location), e.TimeFormat)
+	}
+}
+
+// messagePadding returns the number of spaces needed to pad the message to
+// MinMessageWidth. Together with the field separator written by EncodeFields
+// it puts fields of messages that fit into the width in the same column.
+func (e *ConsoleEncoder) messagePadding(msg string) int {
+	if e.MinMessageWidth == 0 {
+		return 0
+	}
+	return max(e.MinMessageWidth-e.width(msg), 0)
+}
+
+// width returns the width of str in columns, or in bytes if MessageWidthBytes
+// is set.
+func (e *ConsoleEncoder) width(str string) int {
+	if e.MessageWidthBytes {
+		return len(str)
+	}
+	return displayWidth(str)
+}
+
+// writeWrapped writes a message wrapped at WrapWidth, with continuation lines
+// indented to the column the message starts at. It returns the indentation and
+// whether more than one line was written.
+func (e *ConsoleEncoder) writeWrapped(buf *Buffer, msg string) (indent int, wrapped bool) {
+	indent = lastLineWidth(buf.b)
+	if e.Color {
+		buf.WriteString(fontBold)
+	}
+	for i, line := range strings.Split(msg, "\n") {
+		if i > 0 {
+			e.writeLineBreak(buf, indent, line == "")
+			wrapped = true
+		}
+		// Spaces are written before the word that follows them, the ones at
+		// a wrap and at the end of the line are dropped
+		width, spaces := 0, 0
+		for _, word := range strings.Split(line, " ") {
+			if word == "" {
+				spaces++
+				continue
+			}
+			w := e.width(word)
+			if width > 0 && width+spaces+w > e.WrapWidth {
+				e.writeLineBreak(buf, indent, false)
+				wrapped = true
+				width, spaces = 0, 0
+			}
+			for range spaces {
+				buf.WriteBytes(' ')
+			}
+			buf.WriteString(word)
+			width += spaces + w
+			spaces = 1
+		}
+	}
+	if e.Color {
+		buf.WriteString(fontReset)
+	}
+	return indent, wrapped
+}
+
+// writeLineBreak starts a continuation line of a wrapped message. Blank lines
+// are not indented.
+func (e *ConsoleEncoder) writeLineBreak(buf *Buffer, indent int, blank bool) {
+	buf.WriteBytes('\n')
+	if blank {
+		return
+	}
+	for range indent {
+		buf.WriteBytes(' ')
+	}
+}
+
+// lastLineWidth returns the display width of the last line in b, ignoring
+// color escape sequences.
+func lastLineWidth(b []byte) int {
+	b = b[bytes.LastIndexByte(b, '\n')+1:]
+	width := 0
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\033')
+		if i < 0 {
+			return width + displayWidth(string(b))
+		}
+		width += displayWidth(string(b[:i]))
+		b = b[i:]
+		if j := bytes.IndexByte(b, 'm'); j >= 0 {
+			b = b[j+1:]
+		} else {
+			b = nil
+		}
+	}
+	return width
+}
+
 func (e *ConsoleEncoder) writeColorized(buf *Buffer, lev Level, str string) {
 	if !e.Color {
 		buf.WriteString(str)
 		return
 	}
 
-	switch lev {
-	case LevelTrace, LevelDebug:
-		buf.WriteString(colorOffWhite)
-	case LevelInfo:
-		buf.WriteString(colorCyan)
-	case LevelWarn:
-		buf.WriteString(colorYellow)
-	case LevelError:
-		buf.WriteString(colorRed)
-	case LevelPanic, LevelFatal:
-		buf.WriteString(colorRedBg)
-		buf.WriteString(colorWhite)
-	}
+	buf.WriteString(e.palette()[lev])
 	buf.WriteString(str)
 	buf.WriteString(fontReset)
 }
 
+func (e *ConsoleEncoder) palette() *palette {
+	mode := e.ColorMode
+	if mode == ColorModeAuto {
+		mode = detectedColorMode()
+	}
+
+	switch mode {
+	case ColorMode256:
+		return &palette256
+	case ColorModeTrueColor:
+		return &paletteTrueColor
+	default:
+		return &paletteBasic
+	}
+}
+
 func (e *ConsoleEncoder) levelString(lev Level) string {
 	switch lev {
 	case LevelTrace:
@@ -219,3 +726,116 @@ func (e *ConsoleEncoder) levelString(lev Level) string {
 		panic("unreachable")
 	}
 }
+
+// displayWidth returns the number of terminal columns needed to display a
+// string. Wide East Asian characters and emoji take two columns, combining
+// marks and other zero-width characters take none.
+func displayWidth(str string) int {
+	width := 0
+	for i := 0; i < len(str); {
+		b := str[i]
+		if b < utf8.RuneSelf {
+			// Fast path for ASCII
+			i++
+			if b >= 0x20 && b != 0x7f {
+				width++
+			}
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(str[i:])
+		i += size
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc):
+		case isWide(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// wideRanges lists ranges of characters displayed using two columns. The list
+// is based on the Wide and Fullwidth categories of Unicode Standard Annex #11.
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x231A, 0x231B},   // Watch, hourglass
+	{0x2329, 0x232A},   // Angle brackets
+	{0x23E9, 0x23EC},   // Media controls
+	{0x23F0, 0x23F0},   // Alarm clock
+	{0x23F3, 0x23F3},   // Hourglass
+	{0x25FD, 0x25FE},   // Small squares
+	{0x2614, 0x2615},   // Umbrella, hot beverage
+	{0x2648, 0x2653},   // Zodiac signs
+	{0x267F, 0x267F},   // Wheelchair
+	{0x2693, 0x2693},   // Anchor
+	{0x26A1, 0x26A1},   // High voltage
+	{0x26AA, 0x26AB},   // Circles
+	{0x26BD, 0x26BE},   // Soccer ball, baseball
+	{0x26C4, 0x26C5},   // Snowman, sun behind cloud
+	{0x26CE, 0x26CE},   // Ophiuchus
+	{0x26D4, 0x26D4},   // No entry
+	{0x26EA, 0x26EA},   // Church
+	{0x26F2, 0x26F3},   // Fountain, golf
+	{0x26F5, 0x26F5},   // Sailboat
+	{0x26FA, 0x26FA},   // Tent
+	{0x26FD, 0x26FD},   // Fuel pump
+	{0x2705, 0x2705},   // Check mark
+	{0x270A, 0x270B},   // Fists
+	{0x2728, 0x2728},   // Sparkles
+	{0x274C, 0x274C},   // Cross mark
+	{0x274E, 0x274E},   // Cross mark
+	{0x2753, 0x2755},   // Question marks
+	{0x2757, 0x2757},   // Exclamation mark
+	{0x2795, 0x2797},   // Math signs
+	{0x27B0, 0x27B0},   // Curly loop
+	{0x27BF, 0x27BF},   // Double curly loop
+	{0x2B1B, 0x2B1C},   // Large squares
+	{0x2B50, 0x2B50},   // Star
+	{0x2B55, 0x2B55},   // Circle
+	{0x2E80, 0x303E},   // CJK radicals, symbols and punctuation
+	{0x3041, 0x33FF},   // Hiragana, Katakana, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK Unified Ideographs Extension A
+	{0x4E00, 0x9FFF},   // CJK Unified Ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo Extended-A
+	{0xAC00, 0xD7A3},   // Hangul Syllables
+	{0xF900, 0xFAFF},   // CJK Compatibility Ideographs
+	{0xFE10, 0xFE19},   // Vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms, small forms
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x16FE0, 0x18CFF}, // Tangut, Khitan
+	{0x1B000, 0x1B2FF}, // Kana supplement and extensions, Nushu
+	{0x1F004, 0x1F004}, // Mahjong tile
+	{0x1F0CF, 0x1F0CF}, // Playing card
+	{0x1F18E, 0x1F18E}, // AB button
+	{0x1F191, 0x1F19A}, // Squared letters
+	{0x1F200, 0x1F2FF}, // Enclosed ideographic supplement
+	{0x1F300, 0x1F64F}, // Pictographs, emoticons
+	{0x1F680, 0x1F6FF}, // Transport and map symbols
+	{0x1F7E0, 0x1F7EB}, // Colored circles and squares
+	{0x1F90C, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x1FA70, 0x1FAFF}, // Symbols and pictographs extended-A
+	{0x20000, 0x3FFFD}, // CJK Unified Ideographs Extensions B and beyond
+}
+
+func isWide(r rune) bool {
+	if r < wideRanges[0].lo {
+		return false
+	}
+	lo, hi := 0, len(wideRanges)
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		switch {
+		case r < wideRanges[m].lo:
+			hi = m
+		case r > wideRanges[m].hi:
+			lo = m + 1
+		default:
+			return true
+		}
+	}
+	return false
+}
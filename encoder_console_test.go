@@ -0,0 +1,590 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsoleEncoderLineEnding(t *testing.T) {
+	for _, le := range []string{"\r\n", "\x00"} {
+		var buf bytes.Buffer
+		cfg := DefaultConfig()
+		cfg.Output = &buf
+		enc := NewConsoleEncoder()
+		enc.LineEnding = []byte(le)
+		cfg.Encoder = enc
+
+		logger := New(cfg)
+		logger.Info(context.Background(), "First")
+		logger.Info(context.Background(), "Second")
+
+		entries := bytes.Split(buf.Bytes(), []byte(le))
+		if len(entries) != 3 || len(entries[2]) != 0 {
+			t.Errorf("expected 2 entries terminated with %q, got %q", le, buf.String())
+		}
+	}
+}
+
+func TestConsoleEncoderRelativeTime(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Output = &buf
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.RelativeTime = true
+	cfg.Encoder = enc
+	logger := New(cfg)
+	ctx := context.Background()
+
+	tests := []struct {
+		elapsed time.Duration
+		exp     string
+	}{
+		{0, "+0s INFO"},
+		{12*time.Millisecond + 345*time.Microsecond, "+12ms INFO"},
+		{1500 * time.Millisecond, "+1.5s INFO"},
+		{2*time.Minute + 3*time.Second, "+2m3s INFO"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		now = start.Add(tt.elapsed)
+		logger.Info(ctx, "Booting")
+		if !strings.HasPrefix(buf.String(), tt.exp) {
+			t.Errorf("expected prefix %q, got %q", tt.exp, buf.String())
+		}
+	}
+}
+
+type testStatus int
+
+func (s testStatus) String() string {
+	switch s {
+	case 1:
+		return "active"
+	default:
+		return "unknown"
+	}
+}
+
+type testPassword string
+
+func (testPassword) LogValue() any { return "***" }
+
+// testUser logs as a map, with its password masked by testPassword.
+type testUser struct {
+	name     string
+	password testPassword
+}
+
+func (u *testUser) LogValue() any {
+	return map[string]any{"name": u.name, "password": u.password}
+}
+
+// testLoop resolves to itself until n reaches zero.
+type testLoop struct{ n int }
+
+func (l testLoop) LogValue() any {
+	if l.n == 0 {
+		return "done"
+	}
+	return testLoop{l.n - 1}
+}
+
+func TestConsoleEncoderStringerAndError(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Output = &buf
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.MinMessageWidth = 0
+	enc.TimeFormat = ""
+	cfg.Encoder = enc
+	logger := New(cfg)
+
+	logger.Info(context.Background(), "Task", F{
+		"status": testStatus(1),
+		"error":  errors.New("task failed"),
+	})
+	exp := "INFO Task  error=task failed status=active\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestConsoleEncoderColorMode(t *testing.T) {
+	tests := []struct {
+		mode ColorMode
+		exp  string
+	}{
+		{ColorModeBasic, "\033[36mINFO\033[0m"},
+		{ColorMode256, "\033[38;5;80mINFO\033[0m"},
+		{ColorModeTrueColor, "\033[38;2;86;182;194mINFO\033[0m"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		cfg := DefaultConfig()
+		cfg.Output = &buf
+		enc := NewConsoleEncoder()
+		enc.ColorMode = tt.mode
+		enc.TimeFormat = ""
+		cfg.Encoder = enc
+
+		logger := New(cfg)
+		logger.Info(context.Background(), "Colorized")
+		if !strings.HasPrefix(buf.String(), tt.exp) {
+			t.Errorf("mode %d: expected prefix %q, got %q", tt.mode, tt.exp, buf.String())
+		}
+	}
+}
+
+func TestColorModeFromEnv(t *testing.T) {
+	tests := []struct {
+		term      string
+		colorterm string
+		exp       ColorMode
+	}{
+		{"", "", ColorModeBasic},
+		{"xterm", "", ColorModeBasic},
+		{"xterm-256color", "", ColorMode256},
+		{"xterm-256color", "truecolor", ColorModeTrueColor},
+		{"screen", "24bit", ColorModeTrueColor},
+	}
+	for _, tt := range tests {
+		if mode := colorModeFromEnv(tt.term, tt.colorterm); mode != tt.exp {
+			t.Errorf("TERM=%q COLORTERM=%q: expected %d, got %d", tt.term, tt.colorterm, tt.exp, mode)
+		}
+	}
+}
+
+func TestConsoleEncoderFieldAlignment(t *testing.T) {
+	const width = 40
+	tests := []struct {
+		msgLen   int
+		fieldCol int
+	}{
+		{width - 1, width + 2},
+		{width, width + 2},
+		{width + 1, width + 3},
+		{width + 2, width + 4},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc := NewConsoleEncoder()
+		enc.Color = false
+		enc.TimeFormat = ""
+		enc.MinMessageWidth = width
+		logger := New(Config{Output: &buf, Encoder: enc})
+
+		msg := strings.Repeat("x", tt.msgLen)
+		logger.Info(context.Background(), msg, F{"foo": "bar"})
+		line := strings.TrimPrefix(buf.String(), "INFO ")
+		if col := strings.Index(line, "foo="); col != tt.fieldCol {
+			t.Errorf("message length %d: expected fields at column %d, got %d: %q", tt.msgLen, tt.fieldCol, col, line)
+		}
+		if sep := line[tt.msgLen:strings.Index(line, "foo=")]; strings.Trim(sep, " ") != "" {
+			t.Errorf("message length %d: unexpected separator %q", tt.msgLen, sep)
+		}
+	}
+}
+
+func TestConsoleEncoderFieldSeparatorWidth(t *testing.T) {
+	tests := []struct {
+		width int
+		exp   string
+	}{
+		{0, "INFO Task  foo=bar\n"},
+		{1, "INFO Task foo=bar\n"},
+		{4, "INFO Task    foo=bar\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc := NewConsoleEncoder()
+		enc.Color = false
+		enc.TimeFormat = ""
+		enc.MinMessageWidth = 0
+		enc.FieldSeparatorWidth = tt.width
+		logger := New(Config{Output: &buf, Encoder: enc})
+
+		logger.Info(context.Background(), "Task", F{"foo": "bar"})
+		if buf.String() != tt.exp {
+			t.Errorf("width %d: expected %q, got %q", tt.width, tt.exp, buf.String())
+		}
+	}
+}
+
+func TestConsoleEncoderMessagePadding(t *testing.T) {
+	enc := NewConsoleEncoder()
+	enc.MinMessageWidth = 5
+	tests := []struct {
+		msg string
+		exp int
+	}{
+		{"", 5},
+		{"abcd", 1},
+		{"abcde", 0},
+		{"abcdef", 0},
+	}
+	for _, tt := range tests {
+		if got := enc.messagePadding(tt.msg); got != tt.exp {
+			t.Errorf("message %q: expected padding %d, got %d", tt.msg, tt.exp, got)
+		}
+	}
+}
+
+func TestConsoleEncoderMultibyteAlignment(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.TimeFormat = ""
+	enc.MinMessageWidth = 20
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	ctx := context.Background()
+	msgs := []string{"Task started", "タスク開始", "Tâche démarrée", "Task 🚀 started"}
+	for _, msg := range msgs {
+		logger.Info(ctx, msg, F{"foo": "bar"})
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i, line := range lines {
+		before, _, _ := strings.Cut(line, "foo=")
+		if w := displayWidth(before); w != len("INFO ")+20+2 {
+			t.Errorf("message %q: expected fields at column 27, got %d", msgs[i], w)
+		}
+	}
+}
+
+func TestConsoleEncoderMessageWidthBytes(t *testing.T) {
+	enc := NewConsoleEncoder()
+	enc.MinMessageWidth = 10
+	enc.MessageWidthBytes = true
+	if got := enc.messagePadding("日本"); got != 4 {
+		t.Errorf("expected padding 4, got %d", got)
+	}
+	enc.MessageWidthBytes = false
+	if got := enc.messagePadding("日本"); got != 6 {
+		t.Errorf("expected padding 6, got %d", got)
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		str string
+		exp int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"héllo", 5},
+		{"e\u0301", 1},  // Combining acute accent
+		{"日本語", 6},      // CJK ideographs
+		{"한국어", 6},      // Hangul syllables
+		{"ｈｉ", 4},       // Fullwidth forms
+		{"🚀", 2},        // Emoji
+		{"a\u200db", 2}, // Zero width joiner
+		{"\xff", 1},     // Invalid UTF-8 is displayed as the replacement character
+	}
+	for _, tt := range tests {
+		if got := displayWidth(tt.str); got != tt.exp {
+			t.Errorf("%q: expected width %d, got %d", tt.str, tt.exp, got)
+		}
+	}
+}
+
+func TestConsoleEncoderTimeFormatUnix(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.TimeFormat = TimeFormatUnix
+	logger := New(Config{Output: &buf, Encoder: enc})
+	logger.Info(context.Background(), "Task started")
+	if exp := "1714979289 INFO Task started"; !strings.HasPrefix(buf.String(), exp) {
+		t.Errorf("expected prefix %q, got %q", exp, buf.String())
+	}
+}
+
+func TestConsoleEncoderTimePosition(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.TimeFormat = TimeFormatClock
+	enc.Location = time.UTC
+	enc.MinMessageWidth = 15
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	tests := []struct {
+		pos TimePosition
+		exp string
+	}{
+		{TimePositionStart, "07:08:09.000 INFO Task started     id=1\n07:08:09.000 INFO Task done      \n"},
+		{TimePositionEnd, "INFO Task started     id=1 07:08:09.000\nINFO Task done        07:08:09.000\n"},
+		{TimePositionNone, "INFO Task started     id=1\nINFO Task done      \n"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		enc.TimePosition = tt.pos
+		logger.Info(context.Background(), "Task started", F{"id": 1})
+		logger.Info(context.Background(), "Task done")
+		if buf.String() != tt.exp {
+			t.Errorf("position %d: expected %q, got %q", tt.pos, tt.exp, buf.String())
+		}
+	}
+
+	// The time precedes the stack trace
+	buf.Reset()
+	enc.TimePosition = TimePositionEnd
+	logger.Info(context.Background(), "Task failed", Stack())
+	if line, _, _ := strings.Cut(buf.String(), "\n"); line != "INFO Task failed      07:08:09.000" {
+		t.Errorf("expected the time at the end of the first line, got %q", line)
+	}
+}
+
+func TestConsoleEncoderMaps(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.TimeFormat = ""
+	enc.MinMessageWidth = 0
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "Request", F{
+		"headers": map[string]string{"b": "2", "a": "1"},
+		"tags":    map[string]any{"n": 1, "nested": map[string]any{"ok": true}},
+	})
+	exp := "INFO Request  headers={a=1 b=2} tags={n=1 nested={ok=true}}\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+}
+
+func TestConsoleEncoderSlices(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.TimeFormat = ""
+	enc.MinMessageWidth = 0
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "Batch", F{
+		"codes":  []int{1, 2, 3},
+		"ids":    []int64{-1, 2},
+		"names":  []string{"a", "b"},
+		"ratios": []float64{0.5, 1.5},
+		"empty":  []string{},
+	})
+	exp := "INFO Batch  codes=[1 2 3] empty=[] ids=[-1 2] names=[a b] ratios=[0.5 1.5]\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+}
+
+func TestConsoleEncoderNilValue(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.TimeFormat = ""
+	enc.MinMessageWidth = 0
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	var ptr *time.Location
+	var iface error
+	fields := F{"nil": nil, "ptr": ptr, "iface": iface}
+
+	logger.Info(context.Background(), "Request", fields)
+	exp := "INFO Request  iface=null nil=null ptr=null\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+
+	buf.Reset()
+	enc.NilValue = "<nil>"
+	logger.Info(context.Background(), "Request", fields)
+	exp = "INFO Request  iface=<nil> nil=<nil> ptr=<nil>\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+}
+
+func TestConsoleEncoderLogValuer(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewConsoleEncoder()
+	enc.Color = false
+	enc.TimeFormat = ""
+	enc.MinMessageWidth = 0
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	var nilUser *testUser
+	logger.Info(context.Background(), "Login", F{
+		"password": testPassword("hunter2"),
+		"p":        testPassword("hunter2"),
+		"user":     &testUser{name: "bob", password: "hunter2"},
+		"nil_user": nilUser,
+		"nested":   map[string]any{"secret": testPassword("hunter2")},
+		"chain":    testLoop{n: 3},
+		"loop":     testLoop{n: -1},
+	})
+	exp := "INFO Login  chain=done loop=LogValue called too many times on value of type blip.testLoop " +
+		"nested={secret=***} nil_user=null p=*** password=*** user={name=bob password=***}\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+}
+
+func TestConsoleEncoderWrapWidth(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewConsoleEncoder()
+	enc.TimeFormat = ""
+	enc.WrapWidth = 20
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "The quick brown fox jumps over the lazy dog", F{"id": 1, "name": "fox"})
+	exp := "" +
+		"INFO The quick brown fox\n" +
+		"     jumps over the lazy\n" +
+		"     dog\n" +
+		"     id=1 name=fox\n"
+	if got := stripColors(buf.String()); got != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, got)
+	}
+
+	// Color escapes don't count towards the indentation
+	if !strings.Contains(buf.String(), "\n     jumps over the lazy\n") {
+		t.Errorf("expected continuation lines indented to the message column, got %q", buf.String())
+	}
+
+	buf.Reset()
+	enc.Color = false
+	enc.MinMessageWidth = 0
+	logger.Info(context.Background(), "Short message", F{"id": 1})
+	logger.Info(context.Background(), "Line one\nline two")
+	logger.Info(context.Background(), "Unbreakable_message_longer_than_the_width next")
+	exp = "" +
+		"INFO Short message  id=1\n" +
+		"INFO Line one\n" +
+		"     line two\n" +
+		"INFO Unbreakable_message_longer_than_the_width\n" +
+		"     next\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, got)
+	}
+
+	// Blank lines and runs of spaces are kept, spaces at wraps are dropped
+	buf.Reset()
+	logger.Info(context.Background(), "Usage:\n\n  run   the task  quickly now", F{"id": 1})
+	exp = "" +
+		"INFO Usage:\n" +
+		"\n" +
+		"       run   the task\n" +
+		"     quickly now\n" +
+		"     id=1\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, got)
+	}
+}
+
+func stripColors(s string) string {
+	for {
+		i := strings.IndexByte(s, '\033')
+		if i < 0 {
+			return s
+		}
+		j := strings.IndexByte(s[i:], 'm')
+		s = s[:i] + s[i+j+1:]
+	}
+}
+
+func TestConsoleEncoderBoolAsFlag(t *testing.T) {
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return time.Date(2025, 1, 2, 12, 34, 56, 0, time.Local) }
+
+	fields := F{"cached": true, "retried": false, "status": 200}
+	tests := []struct {
+		enc    *ConsoleEncoder
+		fields F
+		exp    string
+	}{
+		{&ConsoleEncoder{SortFields: true}, fields, "INFO Request  cached=true retried=false status=200\n"},
+		{&ConsoleEncoder{SortFields: true, BoolAsFlag: true}, fields, "INFO Request  cached status=200\n"},
+		{&ConsoleEncoder{SortFields: true, BoolAsFlag: true}, F{"retried": false, "status": 200}, "INFO Request  status=200\n"},
+		{&ConsoleEncoder{BoolAsFlag: true}, F{"retried": false}, "INFO Request\n"},
+		{&ConsoleEncoder{BoolAsFlag: true, TimePosition: TimePositionEnd, TimeFormat: TimeFormatClock, FieldSeparatorWidth: 2},
+			F{"retried": false}, "INFO Request  12:34:56.000\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := New(Config{Output: &buf, Encoder: tt.enc})
+		logger.Info(context.Background(), "Request", tt.fields)
+		if got := buf.String(); got != tt.exp {
+			t.Errorf("expected %q, got %q", tt.exp, got)
+		}
+	}
+}
+
+func TestConsoleEncoderSeparators(t *testing.T) {
+	fields := F{"method": "GET", "path": "/users?name=John Doe", "tags": map[string]string{"a": "1"}}
+	tests := []struct {
+		enc *ConsoleEncoder
+		exp string
+	}{
+		{&ConsoleEncoder{SortFields: true},
+			"INFO Request  method=GET path=/users?name=John Doe tags={a=1}\n"},
+		{&ConsoleEncoder{SortFields: true, KeyValueSep: ": ", FieldSep: " | "},
+			"INFO Request  method: GET | path: /users?name=John Doe | tags: {a=1}\n"},
+		{&ConsoleEncoder{SortFields: true, FieldSep: "|"},
+			"INFO Request  method=GET|path=/users?name=John Doe|tags={a=1}\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := New(Config{Output: &buf, Encoder: tt.enc})
+		logger.Info(context.Background(), "Request", fields)
+		if got := buf.String(); got != tt.exp {
+			t.Errorf("expected %q, got %q", tt.exp, got)
+		}
+	}
+}
+
+func TestConsoleEncoderKeyEscaping(t *testing.T) {
+	fields := F{"task id": 1, "a=b": 2, "status": "ok", "nested": map[string]any{"x y": 3}}
+	tests := []struct {
+		enc *ConsoleEncoder
+		exp string
+	}{
+		{&ConsoleEncoder{SortFields: true},
+			`INFO Done  "a=b"=2 nested={"x y"=3} status=ok "task id"=1` + "\n"},
+		{&ConsoleEncoder{SortFields: true, KeyValueSep: ":", FieldSep: ","},
+			`INFO Done  "a=b":2,nested:{"x y"=3},status:ok,"task id":1` + "\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := New(Config{Output: &buf, Encoder: tt.enc})
+		logger.Info(context.Background(), "Done", fields)
+		if got := buf.String(); got != tt.exp {
+			t.Errorf("expected %q, got %q", tt.exp, got)
+		}
+	}
+
+	// Control characters are escaped, separators configured on the encoder
+	// are quoted too
+	var buf Buffer
+	enc := &ConsoleEncoder{FieldSep: "|"}
+	enc.EncodeFields(&buf, LevelInfo, &[]Field{{Key: "a\nb", Value: 1}, {Key: "c|d", Value: 2}})
+	if exp := `  "a\nb"=1|"c|d"=2`; string(buf.Bytes()) != exp {
+		t.Errorf("expected %q, got %q", exp, buf.Bytes())
+	}
+}
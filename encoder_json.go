@@ -3,104 +3,404 @@ package blip
 import (
 	"encoding/base64"
 	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // JSONEncoder is an encoder that encodes log messages in JSON format.
 type JSONEncoder struct {
-	TimeFormat     string
-	TimePrecision  time.Duration
+	TimeFormat    string
+	TimePrecision time.Duration
+	// Location is the time zone of timestamps. Defaults to local time.
+	Location       *time.Location
 	Base64Encoding *base64.Encoding
 	KeyTime        string
 	KeyLevel       string
 	KeyMessage     string
 	KeyStackTrace  string
+	// KeySchemaVersion is the key of the schema version, see SchemaVersion.
+	KeySchemaVersion string
+	// KeySequence is the key of the sequence number field that StackTraceLine
+	// uses to reference entries. It should match Config.SequenceKey.
+	KeySequence string
+	// SchemaVersion is written first in every entry when set, so consumers
+	// can tell which version of the log schema produced it.
+	SchemaVersion string
+	// KeyFields is the key of the object holding fields when NestFields is
+	// enabled.
+	KeyFields string
+	// NestFields writes fields into a nested object instead of the root
+	// object of the entry.
+	NestFields bool
+	// Order controls the order of the time, level, and message components at
+	// the beginning of each entry. Components missing from the list are
+	// omitted. The default order is time, level, message. Only applies when
+	// entries are encoded with Encode, as loggers do, and not to types
+	// embedding the encoder.
+	Order []string
+	// LineEnding is written after each entry. Defaults to a newline.
+	LineEnding []byte
+	// OmitNewline omits the line ending, for outputs that delimit entries
+	// themselves. The logger writes every entry with a single Write call.
+	OmitNewline bool
+	// SortFields sorts fields by key, making the output stable.
+	SortFields bool
+	// OnKeyCollision controls how fields with keys reserved for the time,
+	// level, message, or stack trace are handled.
+	OnKeyCollision KeyCollisionPolicy
+	// FloatFormat is the strconv format of floats, one of 'f', 'g', or 'e'.
+	// By default floats are formatted like encoding/json does: using 'f'
+	// unless they are below 1e-6 or above 1e21.
+	FloatFormat byte
+	// NonFiniteFloats controls how NaN and infinities, which are not valid
+	// JSON numbers, are written. By default they are written as strings.
+	NonFiniteFloats NonFinitePolicy
+	// StackTraceLine writes stack traces on a separate line following their
+	// entry, e.g. {"seq":42,"stacktrace":["main.main /app/main.go:12"]},
+	// keeping large traces out of the entry. The line references the entry by
+	// its sequence number, enable Config.IncludeSequence to have one. Both
+	// lines are written by the logger at once, so no other entry comes in
+	// between. Only applies when entries are encoded with Encode, as loggers
+	// do, and not to types embedding the encoder.
+	StackTraceLine bool
+	// LevelNamedMessage, when set, also writes the message of entries at this
+	// level or above under the name of their level, e.g. "error":"Task
+	// failed", for ingestion systems that detect errors by such keys. The key
+	// is reserved, so fields with the same key, like the one added by Cause,
+	// are handled according to OnKeyCollision. Only applies when entries are
+	// encoded with Encode, like Order.
+	LevelNamedMessage Level
+	// QuoteLargeInts writes integers beyond the range JavaScript numbers
+	// represent exactly, ±(2^53-1), as strings, so JavaScript parsers don't
+	// lose their precision.
+	QuoteLargeInts bool
+	// DurationFormat controls how duration fields are written, as
+	// "1h2m3.5s" (default) or in the ISO 8601 format, e.g. "PT1H2M3.5S".
+	DurationFormat DurationFormat
+	// StackTraceMaxFrames limits the number of frames in stack traces written
+	// by EncodeStackTrace. When zero, the logger sets it to
+	// Config.StackTraceMaxFrames when it is created.
+	StackTraceMaxFrames int
 
-	timeCache func(time.Time) string
+	timeCache timeCache
 }
 
+// KeyCollisionPolicy controls how the JSON encoder handles fields that use one
+// of its reserved keys.
+type KeyCollisionPolicy int
+
+const (
+	// KeyCollisionIgnore writes colliding fields as is, producing duplicate
+	// keys in the output.
+	KeyCollisionIgnore KeyCollisionPolicy = iota
+	// KeyCollisionRename appends an underscore to colliding field keys, e.g.
+	// "message" becomes "message_".
+	KeyCollisionRename
+	// KeyCollisionDrop omits colliding fields.
+	KeyCollisionDrop
+)
+
+// NonFinitePolicy controls how the JSON encoder writes NaN and infinite floats.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteString writes non-finite floats as strings: "NaN", "+Inf", and
+	// "-Inf".
+	NonFiniteString NonFinitePolicy = iota
+	// NonFiniteNull writes non-finite floats as null, same as JavaScript's
+	// JSON.stringify.
+	NonFiniteNull
+)
+
+// JSON encoder header components, see JSONEncoder.Order.
+const (
+	ComponentTime    = "time"
+	ComponentLevel   = "level"
+	ComponentMessage = "message"
+)
+
+var defaultOrder = []string{ComponentTime, ComponentLevel, ComponentMessage}
+
 var _ Encoder = (*JSONEncoder)(nil)
 
 // NewJSONEncoder creates a new JSON encoder with the given configuration.
 // The encoder formats log messages in JSON format, with optional and fields.
 func NewJSONEncoder() *JSONEncoder {
 	return &JSONEncoder{
-		TimeFormat:     defaultTimeFormat,
-		TimePrecision:  defaultTimePrecision,
-		Base64Encoding: base64.StdEncoding,
-		KeyTime:        "time",
-		KeyLevel:       "level",
-		KeyMessage:     "message",
-		KeyStackTrace:  "stacktrace",
+		TimeFormat:       defaultTimeFormat,
+		TimePrecision:    defaultTimePrecision,
+		Base64Encoding:   base64.StdEncoding,
+		KeyTime:          "time",
+		KeyLevel:         "level",
+		KeyMessage:       "message",
+		KeyStackTrace:    "stacktrace",
+		KeySchemaVersion: "v",
+		KeySequence:      defaultSequenceKey,
+		KeyFields:        "fields",
+		LineEnding:       []byte{'\n'},
 	}
 }
 
-// Start writes the beginning of the log message.
-func (e *JSONEncoder) Start(buf *Buffer) {
-	buf.WriteBytes('{')
+// Clone returns a copy of the encoder that shares no state with it.
+func (e *JSONEncoder) Clone() Encoder {
+	c := &JSONEncoder{}
+	*c = *e
+	c.Order = slices.Clone(e.Order)
+	c.LineEnding = slices.Clone(e.LineEnding)
+	c.timeCache = timeCache{}
+	return c
 }
 
-// EncodeTime encodes the time of the log message.
-func (e *JSONEncoder) EncodeTime(buf *Buffer) {
-	if e.TimeFormat == "" {
+// encode encodes a whole entry. It writes the time, level, and message in
+// the configured order and the stack trace on a separate line when
+// StackTraceLine is enabled.
+func (e *JSONEncoder) encode(buf *Buffer, entry Entry) {
+	e.Start(buf)
+	order := e.Order
+	if order == nil {
+		order = defaultOrder
+	}
+	for _, c := range order {
+		switch c {
+		case ComponentTime:
+			e.writeTime(buf, entry.Time)
+		case ComponentLevel:
+			e.writeLevel(buf, entry.Level)
+		case ComponentMessage:
+			e.writeMessage(buf, entry.Message)
+		}
+	}
+	e.writeLevelNamedMessage(buf, entry.Level, entry.Message)
+	e.encodeFields(buf, entry.Fields, e.levelNamedKey(entry.Level))
+	trace := entry.StackTrace
+	if trace == "" {
+		e.End(buf)
 		return
 	}
+	if !e.StackTraceLine {
+		e.writeStackTrace(buf, trace)
+		e.End(buf)
+		return
+	}
+	e.End(buf)
 
-	if e.TimePrecision > 0 {
-		if e.timeCache == nil {
-			e.timeCache = timeCache(e.TimeFormat, e.TimePrecision)
+	buf.WriteBytes('{')
+	if entry.Fields != nil {
+		for _, f := range *entry.Fields {
+			if f.Key == e.KeySequence {
+				buf.WriteEscapedString(f.Key)
+				buf.WriteBytes(':')
+				e.writeAny(buf, f.Value)
+				break
+			}
 		}
-		e.writeSafeField(buf, e.KeyTime, e.timeCache(timeNow()))
-	} else {
-		buf.WriteBytes('"')
-		buf.WriteString(e.KeyTime)
-		buf.WriteBytes('"', ':', '"')
-		buf.WriteTime(timeNow(), e.TimeFormat)
+	}
+	e.writeSeparator(buf)
+	buf.WriteEscapedString(e.KeyStackTrace)
+	buf.WriteBytes(':', '[')
+	// Frames are written as the function name followed by its location
+	lines := strings.Split(strings.TrimSuffix(trace, "\n"), "\n")
+	for i, n := 0, 0; i < len(lines); i, n = i+1, n+1 {
+		frame := lines[i]
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\t") {
+			frame += " " + lines[i+1][1:]
+			i++
+		}
+		if n > 0 {
+			buf.WriteBytes(',')
+		}
+		buf.WriteEscapedString(frame)
+	}
+	buf.WriteBytes(']')
+	e.End(buf)
+}
+
+// Start writes the beginning of the log message.
+func (e *JSONEncoder) Start(buf *Buffer) {
+	buf.WriteBytes('{')
+	if e.SchemaVersion != "" {
 		buf.WriteBytes('"')
+		buf.WriteString(e.KeySchemaVersion)
+		buf.WriteBytes('"', ':')
+		buf.WriteEscapedString(e.SchemaVersion)
 	}
 }
 
+// EncodeTime encodes the time of the log message.
+func (e *JSONEncoder) EncodeTime(buf *Buffer) {
+	e.writeTime(buf, timeNow())
+}
+
 // EncodeLevel encodes the log level of the message.
 func (e *JSONEncoder) EncodeLevel(buf *Buffer, lev Level) {
-	if e.TimeFormat != "" {
-		buf.WriteBytes(',')
-	}
-	e.writeSafeField(buf, e.KeyLevel, e.levelString(lev))
+	e.writeLevel(buf, lev)
 }
 
 // EncodeMessage encodes the log message.
 func (e *JSONEncoder) EncodeMessage(buf *Buffer, msg string) {
-	buf.WriteBytes(',', '"')
-	buf.WriteString(e.KeyMessage)
-	buf.WriteBytes('"', ':')
-	buf.WriteEscapedString(msg)
+	e.writeMessage(buf, msg)
 }
 
 // EncodeFields encodes the fields of the log message.
 func (e *JSONEncoder) EncodeFields(buf *Buffer, _ Level, fields *[]Field) {
+	e.encodeFields(buf, fields, "")
+}
+
+// encodeFields encodes the fields, treating the key of the level named
+// message as reserved if it is not empty.
+func (e *JSONEncoder) encodeFields(buf *Buffer, fields *[]Field, named string) {
 	if fields == nil || len(*fields) == 0 {
 		return
 	}
+	if e.SortFields {
+		sortFields(*fields)
+	}
 
+	if e.NestFields {
+		e.writeSeparator(buf)
+		buf.WriteEscapedString(e.KeyFields)
+		buf.WriteBytes(':', '{')
+	}
 	for _, f := range *fields {
-		buf.WriteBytes(',')
-		buf.WriteEscapedString(f.Key)
+		key := f.Key
+		// Nested fields can't collide with the keys of the root object
+		if !e.NestFields && e.OnKeyCollision != KeyCollisionIgnore && (e.isReserved(key) || key == named) {
+			if e.OnKeyCollision == KeyCollisionDrop {
+				continue
+			}
+			key += "_"
+		}
+
+		e.writeSeparator(buf)
+		buf.WriteEscapedString(key)
 		buf.WriteBytes(':')
 		e.writeAny(buf, f.Value)
 	}
+	if e.NestFields {
+		buf.WriteBytes('}')
+	}
 }
 
 // EncodeStackTrace encodes the stack trace of the log message.
 func (e *JSONEncoder) EncodeStackTrace(buf *Buffer, skip int) {
-	buf.WriteBytes(',', '"')
+	e.writeStackTrace(buf, stackTrace(skip+1, maxFrames(e.StackTraceMaxFrames)))
+}
+
+func (e *JSONEncoder) setStackTraceMaxFrames(n int) {
+	if e.StackTraceMaxFrames == 0 {
+		e.StackTraceMaxFrames = n
+	}
+}
+
+func (e *JSONEncoder) writeStackTrace(buf *Buffer, trace string) {
+	// Every line of the stack trace ends with an escaped newline and every
+	// other line starts with an escaped tab
+	buf.Grow(len(e.KeyStackTrace) + len(trace) + strings.Count(trace, "\n")*2 + 6)
+	e.writeSeparator(buf)
+	buf.WriteBytes('"')
 	buf.WriteString(e.KeyStackTrace)
 	buf.WriteBytes('"', ':')
-	buf.WriteEscapedString(stackTrace(skip))
+	buf.WriteEscapedString(trace)
 }
 
 // End writes the end of the log message.
 func (e *JSONEncoder) End(buf *Buffer) {
-	buf.WriteBytes('}', '\n')
+	buf.WriteBytes('}')
+	if !e.OmitNewline {
+		writeLineEnding(buf, e.LineEnding)
+	}
+}
+
+// ReservedKeys returns the keys used by the encoder for the time, level,
+// message, stack trace, schema version when it is set, and the names of the
+// levels LevelNamedMessage applies to. Fields with the same keys produce
+// duplicate keys in the output.
+func (e *JSONEncoder) ReservedKeys() []string {
+	keys := []string{e.KeyTime, e.KeyLevel, e.KeyMessage, e.KeyStackTrace}
+	if e.SchemaVersion != "" {
+		keys = append(keys, e.KeySchemaVersion)
+	}
+	if e.LevelNamedMessage != 0 {
+		for lev := e.LevelNamedMessage; lev <= LevelFatal; lev++ {
+			keys = append(keys, e.levelString(lev))
+		}
+	}
+	return keys
+}
+
+func (e *JSONEncoder) isReserved(key string) bool {
+	return key == e.KeyTime || key == e.KeyLevel || key == e.KeyMessage || key == e.KeyStackTrace ||
+		e.SchemaVersion != "" && key == e.KeySchemaVersion
+}
+
+func (e *JSONEncoder) writeTime(buf *Buffer, t time.Time) {
+	if e.TimeFormat == "" {
+		return
+	}
+
+	e.writeSeparator(buf)
+	buf.WriteBytes('"')
+	buf.WriteString(e.KeyTime)
+	buf.WriteBytes('"', ':')
+	// Numeric timestamps are not quoted
+	quote := !isNumericTimeFormat(e.TimeFormat)
+	if quote {
+		buf.WriteBytes('"')
+	}
+	if e.TimePrecision > 0 {
+		buf.WriteString(e.timeCache.format(inLocation(t, e.Location), e.TimeFormat, e.TimePrecision))
+	} else {
+		buf.WriteTime(inLocation(t, e.Location), e.TimeFormat)
+	}
+	if quote {
+		buf.WriteBytes('"')
+	}
+}
+
+func (e *JSONEncoder) writeLevel(buf *Buffer, lev Level) {
+	e.writeSeparator(buf)
+	e.writeSafeField(buf, e.KeyLevel, e.levelString(lev))
+}
+
+func (e *JSONEncoder) writeMessage(buf *Buffer, msg string) {
+	// Separator, quoted key, colon, and quoted message
+	buf.Grow(len(e.KeyMessage) + len(msg) + 6)
+	e.writeSeparator(buf)
+	buf.WriteBytes('"')
+	buf.WriteString(e.KeyMessage)
+	buf.WriteBytes('"', ':')
+	buf.WriteEscapedString(msg)
+}
+
+func (e *JSONEncoder) writeLevelNamedMessage(buf *Buffer, lev Level, msg string) {
+	key := e.levelNamedKey(lev)
+	if key == "" {
+		return
+	}
+	e.writeSeparator(buf)
+	buf.WriteBytes('"')
+	buf.WriteString(key)
+	buf.WriteBytes('"', ':')
+	buf.WriteEscapedString(msg)
+}
+
+// levelNamedKey returns the key of the level named message of entries at the
+// level, or an empty string if they don't have one.
+func (e *JSONEncoder) levelNamedKey(lev Level) string {
+	if e.LevelNamedMessage == 0 || lev < e.LevelNamedMessage {
+		return ""
+	}
+	return e.levelString(lev)
+}
+
+// writeSeparator writes a comma unless it is the first field of the object.
+func (e *JSONEncoder) writeSeparator(buf *Buffer) {
+	if len(buf.b) > 0 && buf.b[len(buf.b)-1] != '{' {
+		buf.WriteBytes(',')
+	}
 }
 
 // writeSafeField writes a field to the buffer not worrying about escaping it.
@@ -124,7 +424,7 @@ func (e *JSONEncoder) writeAny(buf *Buffer, val any) {
 	case bool:
 		buf.WriteBool(v)
 	case int:
-		buf.WriteInt(int64(v))
+		e.writeInt(buf, int64(v))
 	case int8:
 		buf.WriteInt(int64(v))
 	case int16:
@@ -132,9 +432,9 @@ func (e *JSONEncoder) writeAny(buf *Buffer, val any) {
 	case int32:
 		buf.WriteInt(int64(v))
 	case int64:
-		buf.WriteInt(v)
+		e.writeInt(buf, v)
 	case uint:
-		buf.WriteUint(uint64(v))
+		e.writeUint(buf, uint64(v))
 	case uint8:
 		buf.WriteUint(uint64(v))
 	case uint16:
@@ -142,25 +442,185 @@ func (e *JSONEncoder) writeAny(buf *Buffer, val any) {
 	case uint32:
 		buf.WriteUint(uint64(v))
 	case uint64:
-		buf.WriteUint(v)
+		e.writeUint(buf, v)
 	case float32:
-		buf.WriteFloat(float64(v), 32)
+		e.writeFloat(buf, float64(v), 32)
 	case float64:
-		buf.WriteFloat(v, 64)
+		e.writeFloat(buf, v, 64)
 	case time.Duration:
 		buf.WriteBytes('"')
-		buf.WriteDuration(v.Truncate(DurationFieldPrecision))
+		writeDuration(buf, v, e.DurationFormat)
 		buf.WriteBytes('"')
 	case time.Time:
 		buf.WriteBytes('"')
 		buf.WriteTime(v, TimeFieldFormat)
 		buf.WriteBytes('"')
+	case LogValuer:
+		if isNil(v) {
+			buf.WriteString("null")
+			return
+		}
+		e.writeAny(buf, ResolveLogValue(v))
+	case error:
+		if isNil(v) {
+			buf.WriteString("null")
+			return
+		}
+		buf.WriteEscapedString(v.Error())
+	case []string:
+		if v == nil {
+			buf.WriteString("null")
+			break
+		}
+		buf.WriteBytes('[')
+		for i, s := range v {
+			if i > 0 {
+				buf.WriteBytes(',')
+			}
+			buf.WriteEscapedString(s)
+		}
+		buf.WriteBytes(']')
+	case []int:
+		if v == nil {
+			buf.WriteString("null")
+			break
+		}
+		buf.WriteBytes('[')
+		for i, n := range v {
+			if i > 0 {
+				buf.WriteBytes(',')
+			}
+			e.writeInt(buf, int64(n))
+		}
+		buf.WriteBytes(']')
+	case []int64:
+		if v == nil {
+			buf.WriteString("null")
+			break
+		}
+		buf.WriteBytes('[')
+		for i, n := range v {
+			if i > 0 {
+				buf.WriteBytes(',')
+			}
+			e.writeInt(buf, n)
+		}
+		buf.WriteBytes(']')
+	case []float64:
+		if v == nil {
+			buf.WriteString("null")
+			break
+		}
+		buf.WriteBytes('[')
+		for i, f := range v {
+			if i > 0 {
+				buf.WriteBytes(',')
+			}
+			e.writeFloat(buf, f, 64)
+		}
+		buf.WriteBytes(']')
+	case map[string]string:
+		if v == nil {
+			buf.WriteString("null")
+			break
+		}
+		buf.WriteBytes('{')
+		for i, k := range sortedKeys(v) {
+			if i > 0 {
+				buf.WriteBytes(',')
+			}
+			buf.WriteEscapedString(k)
+			buf.WriteBytes(':')
+			buf.WriteEscapedString(v[k])
+		}
+		buf.WriteBytes('}')
+	case map[string]any:
+		if v == nil {
+			buf.WriteString("null")
+			break
+		}
+		buf.WriteBytes('{')
+		for i, k := range sortedKeys(v) {
+			if i > 0 {
+				buf.WriteBytes(',')
+			}
+			buf.WriteEscapedString(k)
+			buf.WriteBytes(':')
+			e.writeAny(buf, v[k])
+		}
+		buf.WriteBytes('}')
+	case F:
+		e.writeAny(buf, map[string]any(v))
 	default:
 		//nolint:errchkjson
 		_ = json.NewEncoder(buf).Encode(v)
 	}
 }
 
+func (e *JSONEncoder) writeFloat(buf *Buffer, f float64, bitSize int) {
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		e.writeNonFinite(buf, f)
+		return
+	case e.FloatFormat != 0:
+		buf.b = strconv.AppendFloat(buf.b, f, e.FloatFormat, -1, bitSize)
+		return
+	}
+
+	// Same as encoding/json
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bitSize == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bitSize == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	buf.b = strconv.AppendFloat(buf.b, f, format, -1, bitSize)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		if n := len(buf.b); n >= 4 && buf.b[n-4] == 'e' && buf.b[n-3] == '-' && buf.b[n-2] == '0' {
+			buf.b[n-2] = buf.b[n-1]
+			buf.b = buf.b[:n-1]
+		}
+	}
+}
+
+func (e *JSONEncoder) writeNonFinite(buf *Buffer, f float64) {
+	switch {
+	case e.NonFiniteFloats == NonFiniteNull:
+		buf.WriteString("null")
+	case math.IsNaN(f):
+		buf.WriteString(`"NaN"`)
+	case f > 0:
+		buf.WriteString(`"+Inf"`)
+	default:
+		buf.WriteString(`"-Inf"`)
+	}
+}
+
+// maxSafeInteger is the largest integer JavaScript numbers represent exactly.
+const maxSafeInteger = 1<<53 - 1
+
+func (e *JSONEncoder) writeInt(buf *Buffer, n int64) {
+	if e.QuoteLargeInts && (n > maxSafeInteger || n < -maxSafeInteger) {
+		buf.WriteBytes('"')
+		buf.WriteInt(n)
+		buf.WriteBytes('"')
+		return
+	}
+	buf.WriteInt(n)
+}
+
+func (e *JSONEncoder) writeUint(buf *Buffer, n uint64) {
+	if e.QuoteLargeInts && n > maxSafeInteger {
+		buf.WriteBytes('"')
+		buf.WriteUint(n)
+		buf.WriteBytes('"')
+		return
+	}
+	buf.WriteUint(n)
+}
+
 func (e *JSONEncoder) levelString(lev Level) string {
 	switch lev {
 	case LevelTrace:
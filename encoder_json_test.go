@@ -4,7 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestJSONEncoder(t *testing.T) {
@@ -55,6 +61,178 @@ func TestJSONEncoderNoFieldsNoTime(t *testing.T) {
 	validateAndPrintJSON(t, buf)
 }
 
+func TestJSONEncoderOrder(t *testing.T) {
+	tests := []struct {
+		order []string
+		exp   []string
+	}{
+		{nil, []string{"time", "level", "message", "task_id"}},
+		{
+			[]string{ComponentLevel, ComponentTime, ComponentMessage},
+			[]string{"level", "time", "message", "task_id"},
+		},
+		{
+			[]string{ComponentMessage, ComponentLevel, ComponentTime},
+			[]string{"message", "level", "time", "task_id"},
+		},
+		{
+			[]string{ComponentLevel, ComponentMessage},
+			[]string{"level", "message", "task_id"},
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		cfg := DefaultConfig()
+		cfg.Output = &buf
+		enc := NewJSONEncoder()
+		enc.Order = tt.order
+		cfg.Encoder = enc
+
+		logger := New(cfg)
+		logger.Info(context.Background(), "Starting task", F{"task_id": 123456})
+		validateAndPrintJSON(t, buf)
+		if keys := jsonKeys(t, buf.Bytes()); !slices.Equal(tt.exp, keys) {
+			t.Errorf("expected keys %v, got %v", tt.exp, keys)
+		}
+	}
+}
+
+func TestJSONEncoderOrderTime(t *testing.T) {
+	zone := time.FixedZone("", 5*60*60)
+	for _, at := range []time.Time{
+		time.Date(2025, 1, 1, 12, 0, 0, 0, zone),
+		time.Date(1500, 1, 1, 12, 0, 0, 0, time.UTC),
+	} {
+		var buf bytes.Buffer
+		enc := NewJSONEncoder()
+		enc.TimeFormat = time.RFC3339
+		enc.Location = at.Location()
+		enc.Order = []string{ComponentMessage, ComponentTime}
+		logger := New(Config{Level: LevelInfo, Output: &buf, Encoder: enc})
+		logger.LogAt(context.Background(), at, LevelInfo, "Task done")
+
+		exp := `{"message":"Task done","time":"` + at.Format(time.RFC3339) + `"}` + "\n"
+		if buf.String() != exp {
+			t.Errorf("expected %q, got %q", exp, buf.String())
+		}
+	}
+
+	// The methods can be called on their own
+	enc := NewJSONEncoder()
+	enc.Order = []string{ComponentMessage}
+	buf := &Buffer{}
+	enc.Start(buf)
+	enc.EncodeMessage(buf, "Task done")
+	enc.End(buf)
+	if exp := `{"message":"Task done"}` + "\n"; string(buf.Bytes()) != exp {
+		t.Errorf("expected %q, got %q", exp, buf.Bytes())
+	}
+}
+
+func TestJSONEncoderLineEnding(t *testing.T) {
+	for _, le := range []string{"\r\n", "\x00"} {
+		var buf bytes.Buffer
+		cfg := DefaultConfig()
+		cfg.Output = &buf
+		enc := NewJSONEncoder()
+		enc.LineEnding = []byte(le)
+		cfg.Encoder = enc
+
+		logger := New(cfg)
+		logger.Info(context.Background(), "First")
+		logger.Info(context.Background(), "Second")
+
+		entries := bytes.Split(buf.Bytes(), []byte(le))
+		if len(entries) != 3 || len(entries[2]) != 0 {
+			t.Fatalf("expected 2 entries terminated with %q, got %q", le, buf.String())
+		}
+		for _, entry := range entries[:2] {
+			validateAndPrintJSON(t, *bytes.NewBuffer(entry))
+		}
+	}
+}
+
+func TestJSONEncoderSortFields(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Output = &buf
+	enc := NewJSONEncoder()
+	enc.SortFields = true
+	cfg.Encoder = enc
+
+	logger := New(cfg)
+	ctx := ContextWithFields(context.Background(), F{"b": 2, "y": 25})
+	logger.Info(ctx, "Sorted", F{"z": 26, "a": 1, "x": 24, "c": 3})
+	validateAndPrintJSON(t, buf)
+	exp := []string{"time", "level", "message", "a", "b", "c", "x", "y", "z"}
+	if keys := jsonKeys(t, buf.Bytes()); !slices.Equal(exp, keys) {
+		t.Errorf("expected keys %v, got %v", exp, keys)
+	}
+}
+
+func TestJSONEncoderKeyCollision(t *testing.T) {
+	tests := []struct {
+		policy KeyCollisionPolicy
+		exp    []string
+	}{
+		{KeyCollisionIgnore, []string{"time", "level", "message", "message"}},
+		{KeyCollisionRename, []string{"time", "level", "message", "message_"}},
+		{KeyCollisionDrop, []string{"time", "level", "message"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		cfg := DefaultConfig()
+		cfg.Output = &buf
+		enc := NewJSONEncoder()
+		enc.OnKeyCollision = tt.policy
+		cfg.Encoder = enc
+
+		logger := New(cfg)
+		logger.Info(context.Background(), "Starting task", F{"message": "shadowed"})
+		validateAndPrintJSON(t, buf)
+		if keys := jsonKeys(t, buf.Bytes()); !slices.Equal(tt.exp, keys) {
+			t.Errorf("policy %d: expected keys %v, got %v", tt.policy, tt.exp, keys)
+		}
+	}
+}
+
+func TestJSONEncoderError(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Output = &buf
+	cfg.Encoder = NewJSONEncoder()
+	logger := New(cfg)
+
+	logger.Error(context.Background(), "Failed to process task", F{
+		"error": fmt.Errorf("task %d: %w", 123456, errors.New(`"quoted" failure`)),
+	})
+	var out map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+	}
+	if exp := `task 123456: "quoted" failure`; out["error"] != exp {
+		t.Errorf("expected error %q, got %v", exp, out["error"])
+	}
+}
+
+type testError struct{ msg string }
+
+func (e *testError) Error() string { return e.msg }
+
+func TestJSONEncoderNilError(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.TimeFormat = ""
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	var err *testError
+	logger.Info(context.Background(), "Done", F{"err": err})
+	exp := `{"level":"info","message":"Done","err":null}` + "\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+}
+
 func validateAndPrintJSON(t *testing.T, buf bytes.Buffer) {
 	t.Helper()
 	if buf.Len() == 0 {
@@ -66,3 +244,455 @@ func validateAndPrintJSON(t *testing.T, buf bytes.Buffer) {
 		t.Errorf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
 	}
 }
+
+// jsonKeys returns the top-level keys of a JSON object in order.
+func jsonKeys(t *testing.T, data []byte) []string {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		t.Fatalf("Failed to read JSON: %v", err)
+	}
+
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatalf("Failed to read JSON: %v", err)
+		}
+		keys = append(keys, tok.(string))
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			t.Fatalf("Failed to read JSON: %v", err)
+		}
+	}
+	return keys
+}
+
+func TestJSONEncoderTimeFormatPresets(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.FixedZone("", 2*3600))
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	tests := []struct {
+		format string
+		exp    any
+	}{
+		{TimeFormatISO8601, "2024-05-06T07:08:09.123+02:00"},
+		{TimeFormatUnix, json.Number("1714972089")},
+		{TimeFormatUnixMilli, json.Number("1714972089123")},
+		{TimeFormatUnixNano, json.Number("1714972089123456789")},
+	}
+	for _, tt := range tests {
+		for _, precision := range []time.Duration{0, time.Millisecond} {
+			var buf bytes.Buffer
+			enc := NewJSONEncoder()
+			enc.TimeFormat = tt.format
+			enc.TimePrecision = precision
+			logger := New(Config{Output: &buf, Encoder: enc})
+			logger.Info(context.Background(), "Task started")
+
+			var out map[string]any
+			dec := json.NewDecoder(&buf)
+			dec.UseNumber()
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+			}
+			if out["time"] != tt.exp {
+				t.Errorf("format %q, precision %v: expected %#v, got %#v", tt.format, precision, tt.exp, out["time"])
+			}
+		}
+	}
+}
+
+func TestJSONEncoderMaps(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.TimeFormat = ""
+	enc.SortFields = true
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "Request", F{
+		"headers": map[string]string{"b": "2", "a": "1\n"},
+		"tags":    map[string]any{"n": 1, "nested": map[string]any{"ok": true}, "nil": nil},
+	})
+	exp := `{"level":"info","message":"Request","headers":{"a":"1\n","b":"2"},"tags":{"n":1,"nested":{"ok":true},"nil":null}}` + "\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+
+	// Nil maps are written as null, like encoding/json does
+	buf.Reset()
+	logger.Info(context.Background(), "Request", F{
+		"headers": map[string]string(nil),
+		"tags":    map[string]any(nil),
+		"attrs":   F(nil),
+	})
+	exp = `{"level":"info","message":"Request","attrs":null,"headers":null,"tags":null}` + "\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+}
+
+func TestJSONEncoderSlices(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.TimeFormat = ""
+	enc.SortFields = true
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "Batch", F{
+		"codes":  []int{1, 2, 3},
+		"empty":  []string{},
+		"ids":    []int64{-1, 9007199254740993},
+		"names":  []string{"a", "b\"c"},
+		"nil":    []int(nil),
+		"ratios": []float64{0.5, 1e21},
+	})
+	exp := `{"level":"info","message":"Batch","codes":[1,2,3],"empty":[],"ids":[-1,9007199254740993],"names":["a","b\"c"],"nil":null,"ratios":[0.5,1e+21]}` + "\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+}
+
+func TestJSONEncoderLocation(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("", -7*3600))
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.TimeFormat = time.RFC3339
+	logger := New(Config{Output: &buf, Encoder: enc})
+	ctx := context.Background()
+
+	tests := []struct {
+		loc *time.Location
+		exp string
+	}{
+		{nil, "2024-05-06T07:08:09-07:00"},
+		// Cached timestamps are not reused for another location
+		{time.UTC, "2024-05-06T14:08:09Z"},
+		{time.FixedZone("", 9*3600), "2024-05-06T23:08:09+09:00"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		enc.Location = tt.loc
+		logger.Info(ctx, "Task started")
+
+		var out map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+		}
+		if out["time"] != tt.exp {
+			t.Errorf("location %v: expected %q, got %q", tt.loc, tt.exp, out["time"])
+		}
+	}
+}
+
+func TestJSONEncoderNestFields(t *testing.T) {
+	tests := []struct {
+		nest   bool
+		fields []F
+		exp    string
+	}{
+		{false, []F{{"a": 1, "message": "x"}}, `{"level":"info","message":"Task","a":1,"message_":"x"}`},
+		{true, []F{{"a": 1, "message": "x"}}, `{"level":"info","message":"Task","fields":{"a":1,"message":"x"}}`},
+		{true, nil, `{"level":"info","message":"Task"}`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc := NewJSONEncoder()
+		enc.TimeFormat = ""
+		enc.SortFields = true
+		enc.OnKeyCollision = KeyCollisionRename
+		enc.NestFields = tt.nest
+		logger := New(Config{Output: &buf, Encoder: enc, StackTraceLevel: LevelPanic})
+
+		logger.Info(context.Background(), "Task", tt.fields...)
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != tt.exp {
+			t.Errorf("nest %v: expected %s, got %s", tt.nest, tt.exp, got)
+		}
+	}
+}
+
+func TestJSONEncoderNestFieldsStackTrace(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.NestFields = true
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Error(context.Background(), "Task failed", F{"a": 1})
+	var out map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+	}
+	if fields, _ := out["fields"].(map[string]any); fields["a"] != float64(1) {
+		t.Errorf("expected nested fields, got %v", out["fields"])
+	}
+	if _, ok := out["stacktrace"]; !ok {
+		t.Errorf("expected stack trace at the root, got %v", out)
+	}
+}
+
+func TestJSONEncoderQuoteLargeInts(t *testing.T) {
+	fields := F{
+		"int":      1<<53 - 1,
+		"int_big":  1<<53 + 1,
+		"neg":      -(1<<53 - 1),
+		"neg_big":  int64(-(1<<53 + 1)),
+		"uint":     uint64(1<<53 - 1),
+		"uint_big": uint64(1<<64 - 1),
+		"ids":      []int64{1, 1 << 60},
+	}
+	for _, quote := range []bool{false, true} {
+		var buf bytes.Buffer
+		enc := NewJSONEncoder()
+		enc.TimeFormat = ""
+		enc.SortFields = true
+		enc.QuoteLargeInts = quote
+		logger := New(Config{Output: &buf, Encoder: enc})
+
+		logger.Info(context.Background(), "IDs", fields)
+		exp := `{"level":"info","message":"IDs","ids":[1,1152921504606846976],"int":9007199254740991,"int_big":9007199254740993,` +
+			`"neg":-9007199254740991,"neg_big":-9007199254740993,"uint":9007199254740991,"uint_big":18446744073709551615}` + "\n"
+		if quote {
+			exp = `{"level":"info","message":"IDs","ids":[1,"1152921504606846976"],"int":9007199254740991,"int_big":"9007199254740993",` +
+				`"neg":-9007199254740991,"neg_big":"-9007199254740993","uint":9007199254740991,"uint_big":"18446744073709551615"}` + "\n"
+		}
+		if got := buf.String(); got != exp {
+			t.Errorf("quote %v: expected %s, got %s", quote, exp, got)
+		}
+	}
+}
+
+func TestJSONEncoderFloats(t *testing.T) {
+	values := []float64{0, 1, -1.5, 1e20, 1e21, 1e-6, 1e-7, 1e-10, 123456789.125, math.MaxFloat64, math.SmallestNonzeroFloat64}
+	for _, v := range values {
+		var buf bytes.Buffer
+		enc := NewJSONEncoder()
+		logger := New(Config{Output: &buf, Encoder: enc})
+		logger.Info(context.Background(), "Float", F{"f64": v, "f32": float32(v)})
+
+		var out map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+		}
+		f64, _ := json.Marshal(v)
+		f32, _ := json.Marshal(float32(v))
+		if !strings.Contains(buf.String(), `"f64":`+string(f64)) || !strings.Contains(buf.String(), `"f32":`+string(f32)) {
+			t.Errorf("expected %s and %s as encoding/json writes them, got %s", f64, f32, buf.String())
+		}
+	}
+}
+
+func TestJSONEncoderFloatFormat(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.TimeFormat = ""
+	enc.SortFields = true
+	enc.FloatFormat = 'f'
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "Float", F{"big": 1e21, "small": 1e-7})
+	exp := `{"level":"info","message":"Float","big":1000000000000000000000,"small":0.0000001}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("expected %s, got %s", exp, buf.String())
+	}
+}
+
+func TestJSONEncoderNonFiniteFloats(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.TimeFormat = ""
+	enc.SortFields = true
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "Float", F{
+		"inf":  math.Inf(1),
+		"nan":  math.NaN(),
+		"ninf": float32(math.Inf(-1)),
+	})
+	exp := `{"level":"info","message":"Float","inf":"+Inf","nan":"NaN","ninf":"-Inf"}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("expected %s, got %s", exp, buf.String())
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Errorf("expected valid JSON, got %s", buf.String())
+	}
+
+	buf.Reset()
+	enc.NonFiniteFloats = NonFiniteNull
+	logger.Info(context.Background(), "Float", F{
+		"inf":    math.Inf(1),
+		"nan":    math.NaN(),
+		"ninf":   float32(math.Inf(-1)),
+		"ratios": []float64{0.5, math.NaN()},
+	})
+	exp = `{"level":"info","message":"Float","inf":null,"nan":null,"ninf":null,"ratios":[0.5,null]}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("expected %s, got %s", exp, buf.String())
+	}
+}
+
+func TestJSONEncoderLogValuer(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.TimeFormat = ""
+	enc.SortFields = true
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	var nilUser *testUser
+	logger.Info(context.Background(), "Login", F{
+		"password": testPassword("hunter2"),
+		"p":        testPassword("hunter2"),
+		"user":     &testUser{name: "bob", password: "hunter2"},
+		"nil_user": nilUser,
+		"nested":   map[string]any{"secret": testPassword("hunter2")},
+		"chain":    testLoop{n: 3},
+		"loop":     testLoop{n: -1},
+	})
+	exp := `{"level":"info","message":"Login","chain":"done",` +
+		`"loop":"LogValue called too many times on value of type blip.testLoop",` +
+		`"nested":{"secret":"***"},"nil_user":null,"p":"***","password":"***","user":{"name":"bob","password":"***"}}` + "\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+}
+
+func TestJSONEncoderSchemaVersion(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.SchemaVersion = "2"
+	enc.Order = []string{ComponentMessage, ComponentLevel}
+	enc.OnKeyCollision = KeyCollisionRename
+	logger := New(Config{Output: &buf, Encoder: enc})
+
+	logger.Info(context.Background(), "First")
+	logger.Info(context.Background(), "Second", F{"v": "field"})
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte{'\n'})
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var out map[string]any
+		if err := json.Unmarshal(line, &out); err != nil {
+			t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, line)
+		}
+		if n := bytes.Count(line, []byte(`"v":`)); n != 1 {
+			t.Errorf("expected the version once, found %d times in %s", n, line)
+		}
+		if !bytes.HasPrefix(line, []byte(`{"v":"2","message":`)) {
+			t.Errorf("expected the version first, got %s", line)
+		}
+	}
+	if !bytes.Contains(lines[1], []byte(`"v_":"field"`)) {
+		t.Errorf("expected the colliding field to be renamed, got %s", lines[1])
+	}
+}
+
+func TestJSONEncoderLevelNamedMessage(t *testing.T) {
+	for _, order := range [][]string{nil, {ComponentMessage, ComponentLevel}} {
+		var buf bytes.Buffer
+		enc := NewJSONEncoder()
+		enc.TimeFormat = ""
+		enc.Order = order
+		enc.LevelNamedMessage = LevelError
+		logger := New(Config{Output: &buf, Encoder: enc, StackTraceLevel: LevelFatal})
+
+		ctx := context.Background()
+		logger.Warn(ctx, "Disk almost full")
+		logger.Error(ctx, "Task failed", F{"task_id": 1})
+		logger.Error(ctx, "No fields")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines, got %d", len(lines))
+		}
+		for _, line := range lines {
+			if !json.Valid([]byte(line)) {
+				t.Errorf("expected valid JSON, got %s", line)
+			}
+		}
+		if strings.Contains(lines[0], `"warn":`) {
+			t.Errorf("expected no level named message below the level, got %s", lines[0])
+		}
+		exp := []string{
+			`"message":"Task failed","error":"Task failed","task_id":1}`,
+			`"message":"No fields","error":"No fields"}`,
+		}
+		if order != nil {
+			exp = []string{
+				`{"message":"Task failed","level":"error","error":"Task failed","task_id":1}`,
+				`{"message":"No fields","level":"error","error":"No fields"}`,
+			}
+		}
+		for i, e := range exp {
+			if !strings.Contains(lines[i+1], e) {
+				t.Errorf("expected %s in %s", e, lines[i+1])
+			}
+		}
+	}
+
+	// The level named key is reserved
+	var buf bytes.Buffer
+	enc := &JSONEncoder{KeyLevel: "level", KeyMessage: "message", LevelNamedMessage: LevelError, OnKeyCollision: KeyCollisionRename}
+	logger := New(Config{Output: &buf, Encoder: enc, StackTraceLevel: LevelFatal})
+	logger.Error(context.Background(), "Task failed", Cause(errors.New("timeout")))
+	if exp := `{"level":"error","message":"Task failed","error":"Task failed","error_":"timeout"}` + "\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+	if keys := enc.ReservedKeys(); !slices.Contains(keys, "error") || !slices.Contains(keys, "fatal") || slices.Contains(keys, "warn") {
+		t.Errorf("expected level names from error up in reserved keys, got %v", keys)
+	}
+}
+
+func TestJSONEncoderStackTraceLine(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder()
+	enc.TimeFormat = ""
+	enc.StackTraceLine = true
+	logger := New(Config{
+		Output:          &buf,
+		Encoder:         enc,
+		StackTraceLevel: LevelError,
+		IncludeSequence: true,
+	})
+
+	logger.Info(context.Background(), "Task started")
+	logger.Error(context.Background(), "Task failed")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d:\n%s", len(lines), buf.String())
+	}
+
+	var entry struct {
+		Seq        uint64
+		StackTrace any `json:"stacktrace"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, lines[1])
+	}
+	if entry.Seq != 2 || entry.StackTrace != nil {
+		t.Errorf("expected entry 2 without a stack trace, got %s", lines[1])
+	}
+
+	var companion struct {
+		Seq        uint64
+		StackTrace []string `json:"stacktrace"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &companion); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, lines[2])
+	}
+	if companion.Seq != entry.Seq {
+		t.Errorf("expected the stack trace to reference entry %d, got %s", entry.Seq, lines[2])
+	}
+	if len(companion.StackTrace) == 0 {
+		t.Fatalf("expected stack frames, got %s", lines[2])
+	}
+	i := slices.IndexFunc(companion.StackTrace, func(frame string) bool {
+		return strings.HasPrefix(frame, "github.com/localhots/blip.TestJSONEncoderStackTraceLine ")
+	})
+	if i < 0 || !strings.Contains(companion.StackTrace[i], "/encoder_json_test.go:") {
+		t.Errorf("expected a frame of the test function with its location, got %q", companion.StackTrace)
+	}
+}
@@ -0,0 +1,156 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nameEncoder prefixes entries with the logger name using the entry path.
+type nameEncoder struct {
+	*ConsoleEncoder
+	entries []Entry
+}
+
+func (e *nameEncoder) Encode(buf *Buffer, entry Entry) {
+	e.entries = append(e.entries, entry)
+	buf.WriteString("[" + entry.Name + "] ")
+	EncodeEntry(e.ConsoleEncoder, buf, entry)
+}
+
+func TestEntryEncoder(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	enc := &nameEncoder{ConsoleEncoder: &ConsoleEncoder{}}
+	logger := New(Config{Output: &buf, Encoder: enc, Name: "db"})
+
+	logger.Warn(context.Background(), "Slow query", F{"ms": 120})
+	if exp := "[db] WARN Slow query  logger=db ms=120\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+	if len(enc.entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(enc.entries))
+	}
+	if e := enc.entries[0]; !e.Time.Equal(now) || e.Level != LevelWarn || e.Message != "Slow query" || e.StackTrace != "" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestEncodeEntry(t *testing.T) {
+	// The stack trace of an entry not being logged can't be encoded
+	fields := []Field{{"task_id", 1}}
+	buf := &Buffer{}
+	EncodeEntry(&ConsoleEncoder{}, buf, Entry{
+		Level:      LevelError,
+		Message:    "Task failed",
+		Fields:     &fields,
+		StackTrace: "main.main\n\tmain.go:1\n",
+	})
+	if exp := "ERRO Task failed  task_id=1\n"; string(buf.Bytes()) != exp {
+		t.Errorf("expected %q, got %q", exp, buf.Bytes())
+	}
+
+	var out bytes.Buffer
+	logger := New(Config{
+		Output:          &out,
+		Encoder:         shoutEncoder{&ConsoleEncoder{}},
+		StackTraceLevel: LevelError,
+		StackTraceSkip:  3,
+	})
+	logger.Error(context.Background(), "Task failed")
+	if exp := "ERRO TASK FAILED\ngithub.com/localhots/blip.TestEncodeEntry\n"; !strings.HasPrefix(out.String(), exp) {
+		t.Errorf("expected stack trace to start at the caller, got %q", out.String())
+	}
+}
+
+// shoutEncoder overrides a method of the embedded console encoder.
+type shoutEncoder struct {
+	*ConsoleEncoder
+}
+
+func (e shoutEncoder) EncodeMessage(buf *Buffer, msg string) {
+	e.ConsoleEncoder.EncodeMessage(buf, strings.ToUpper(msg))
+}
+
+func TestEncodeEmbedded(t *testing.T) {
+	for _, pos := range []TimePosition{TimePositionStart, TimePositionEnd} {
+		buf := &Buffer{}
+		Encode(shoutEncoder{&ConsoleEncoder{TimePosition: pos}}, buf, Entry{
+			Level:   LevelInfo,
+			Message: "Task started",
+		})
+		if exp := "INFO TASK STARTED\n"; string(buf.Bytes()) != exp {
+			t.Errorf("expected %q, got %q", exp, buf.Bytes())
+		}
+	}
+}
+
+// frameWriter records every write as a separate frame.
+type frameWriter struct {
+	frames []string
+}
+
+func (w *frameWriter) Write(b []byte) (int, error) {
+	w.frames = append(w.frames, string(b))
+	return len(b), nil
+}
+
+func TestOmitNewline(t *testing.T) {
+	tests := []struct {
+		enc Encoder
+		exp []string
+	}{
+		{&ConsoleEncoder{OmitNewline: true}, []string{"INFO First", "INFO Second  a=1"}},
+		{&JSONEncoder{KeyLevel: "level", KeyMessage: "message", OmitNewline: true}, []string{
+			`{"level":"info","message":"First"}`,
+			`{"level":"info","message":"Second","a":1}`,
+		}},
+	}
+	for _, tt := range tests {
+		var w frameWriter
+		logger := New(Config{Output: &w, Encoder: tt.enc})
+		logger.Info(context.Background(), "First")
+		logger.Info(context.Background(), "Second", F{"a": 1})
+		if strings.Join(w.frames, "|") != strings.Join(tt.exp, "|") {
+			t.Errorf("expected frames %q, got %q", tt.exp, w.frames)
+		}
+	}
+}
+
+func TestNewEncoderByName(t *testing.T) {
+	if enc, err := NewEncoderByName("json"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, ok := enc.(*JSONEncoder); !ok {
+		t.Errorf("expected *JSONEncoder, got %T", enc)
+	}
+	if enc, err := NewEncoderByName("Console"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, ok := enc.(*ConsoleEncoder); !ok {
+		t.Errorf("expected *ConsoleEncoder, got %T", enc)
+	}
+
+	RegisterEncoder("shout", func() Encoder { return shoutEncoder{&ConsoleEncoder{}} })
+	defer func() {
+		encodersMu.Lock()
+		delete(encoders, "shout")
+		encodersMu.Unlock()
+	}()
+	enc, err := NewEncoderByName("shout")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	New(Config{Output: &buf, Encoder: enc}).Info(context.Background(), "Hello")
+	if exp := "INFO HELLO\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+
+	if _, err := NewEncoderByName("xml"); err == nil || !strings.Contains(err.Error(), `"xml"`) {
+		t.Errorf("expected unknown encoder error, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+//go:build windows
+
+// Package eventlog provides a writer that reports log entries to the Windows
+// Event Log. Entries are reported as events with a type matching their level:
+//
+//	w, err := eventlog.NewWriter("MyService")
+//	if err != nil {
+//		return err
+//	}
+//	logger := blip.New(blip.Config{
+//		Output:  w,
+//		Encoder: blip.NewJSONEncoder(),
+//	})
+//
+// The event source must be registered in the registry for the Event Viewer to
+// display messages without a warning. It is only available on Windows.
+package eventlog
+
+import (
+	"bytes"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/localhots/blip"
+)
+
+// Event types, see ReportEventW.
+const (
+	TypeError       = 0x0001
+	TypeWarning     = 0x0002
+	TypeInformation = 0x0004
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+)
+
+// EventType returns the event type of a level.
+func EventType(lev blip.Level) uint16 {
+	switch {
+	case lev <= blip.LevelInfo:
+		return TypeInformation
+	case lev == blip.LevelWarn:
+		return TypeWarning
+	default:
+		return TypeError
+	}
+}
+
+// Writer reports entries to the event log. It implements blip.LevelWriter, so
+// loggers pass it the level of every entry. It is safe for concurrent use.
+type Writer struct {
+	// EventID is the identifier of reported events.
+	EventID uint32
+
+	handle uintptr
+}
+
+// NewWriter opens the event log for the given source.
+func NewWriter(source string) (*Writer, error) {
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+	h, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, fmt.Errorf("register event source: %w", err)
+	}
+	return &Writer{EventID: 1, handle: h}, nil
+}
+
+var _ blip.LevelWriter = (*Writer)(nil)
+
+// WriteLevel reports an entry as an event with the type matching its level.
+func (w *Writer) WriteLevel(lev blip.Level, b []byte) (int, error) {
+	return w.report(EventType(lev), b)
+}
+
+// Write reports an entry as an information event. Loggers call it for entries
+// written with WriteRaw, which have no level.
+func (w *Writer) Write(b []byte) (int, error) {
+	return w.report(TypeInformation, b)
+}
+
+func (w *Writer) report(typ uint16, b []byte) (int, error) {
+	msg, err := syscall.UTF16PtrFromString(string(bytes.TrimRight(b, "\r\n")))
+	if err != nil {
+		return 0, err
+	}
+	ok, _, err := procReportEventW.Call(
+		w.handle,
+		uintptr(typ),
+		0, // Category
+		uintptr(w.EventID),
+		0, // User SID
+		1, // Number of strings
+		0, // Raw data size
+		uintptr(unsafe.Pointer(&msg)),
+		0, // Raw data
+	)
+	if ok == 0 {
+		return 0, fmt.Errorf("report event: %w", err)
+	}
+	return len(b), nil
+}
+
+// Close closes the event log.
+func (w *Writer) Close() error {
+	if ok, _, err := procDeregisterEventSource.Call(w.handle); ok == 0 {
+		return fmt.Errorf("deregister event source: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package eventlog
+
+import (
+	"testing"
+
+	"github.com/localhots/blip"
+)
+
+func TestEventType(t *testing.T) {
+	exp := map[blip.Level]uint16{
+		blip.LevelTrace: TypeInformation,
+		blip.LevelDebug: TypeInformation,
+		blip.LevelInfo:  TypeInformation,
+		blip.LevelWarn:  TypeWarning,
+		blip.LevelError: TypeError,
+		blip.LevelPanic: TypeError,
+		blip.LevelFatal: TypeError,
+	}
+	for lev, typ := range exp {
+		if got := EventType(lev); got != typ {
+			t.Errorf("expected event type %d for %s, got %d", typ, lev, got)
+		}
+	}
+}
@@ -2,7 +2,12 @@ package blip
 
 import (
 	"context"
-	"sync"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Field is a key-value pair that is used to add structured data to log entries.
@@ -14,32 +19,192 @@ type Field struct {
 // F is a convenient alias for a map of fields.
 type F map[string]any
 
-// makeFields creates a slice of fields from the given context and field sets.
-// Explicitly logged fields take precedence over context fields. Last field set
-// wins if there are duplicates.
-func makeFields(ctx context.Context, ff []F) *[]Field {
+// LogValuer is implemented by types that control how their values are logged,
+// regardless of the key of the field. Encoders log the value returned by
+// LogValue in place of the original one, e.g. a password type can always be
+// logged as "***":
+//
+//	type Password string
+//
+//	func (Password) LogValue() any { return "***" }
+type LogValuer interface {
+	LogValue() any
+}
+
+// maxLogValues is the number of times LogValue is called on values that
+// resolve to other LogValuers, like slog does, so that values resolving to
+// themselves can't loop forever.
+const maxLogValues = 100
+
+// ResolveLogValue returns the value v resolves to, calling LogValue until the
+// result isn't a LogValuer. Custom encoders use it to log LogValuers. If the
+// value doesn't resolve in 100 calls, an error is returned instead.
+func ResolveLogValue(v LogValuer) any {
+	orig := v
+	for range maxLogValues {
+		val := v.LogValue()
+		next, ok := val.(LogValuer)
+		if !ok || isNil(next) {
+			return val
+		}
+		v = next
+	}
+	return fmt.Errorf("LogValue called too many times on value of type %T", orig)
+}
+
+// stackMarker is a field value that forces a stack trace to be logged, see
+// Stack.
+type stackMarker struct{}
+
+const stackMarkerKey = "\x00stack"
+
+// Stack returns a field set that forces a stack trace to be logged with the
+// entry, regardless of the configured StackTraceLevel.
+func Stack() F {
+	return F{stackMarkerKey: stackMarker{}}
+}
+
+// callerSkip is a field value that skips additional frames when looking up the
+// caller and the stack trace, see CallerSkip.
+type callerSkip int
+
+const callerSkipKey = "\x00caller_skip"
+
+// CallerSkip returns a field set that skips the given number of additional
+// stack frames when looking up the caller and the stack trace of the entry.
+// Logging helpers use it to attribute entries to their own callers:
+//
+//	func logFailure(ctx context.Context, err error) {
+//		logger.Error(ctx, "Task failed", blip.Cause(err), blip.CallerSkip(1))
+//	}
+func CallerSkip(n int) F {
+	return F{callerSkipKey: callerSkip(n)}
+}
+
+// entryTime is a field value that replaces the time of the entry, see LogAt.
+type entryTime time.Time
+
+const entryTimeKey = "\x00time"
+
+// markers holds the options requested with marker fields.
+type markers struct {
+	stack bool      // See Stack
+	skip  int       // See CallerSkip
+	at    time.Time // See LogAt
+}
+
+// makeFields creates a slice of fields from the given base fields, context,
+// and field sets. Explicitly logged fields take precedence over context fields,
+// which take precedence over base fields. Last field set wins if there are
+// duplicates, so every key appears in the slice at most once. Keys are
+// normalized with norm, if set, before duplicates are resolved. Stack traces of
+// errors logged with Cause are limited to maxFrames frames. Error fields
+// of the context are included at the error level and above. Context fields
+// are grouped into a single field under the group key, if set. The slice is
+// taken from the given pools. It also returns the options requested with
+// Stack, CallerSkip, and LogAt.
+func makeFields(ctx context.Context, lev Level, p *pools, norm func(string) string, maxFrames int, group string, base F, ff []F) (fields *[]Field, m markers) {
 	cf := FieldsFromContext(ctx)
-	n := len(cf)
+	if lev >= LevelError {
+		if ef := ErrorFieldsFromContext(ctx); len(ef) > 0 {
+			cf = mergeFields(ef, cf)
+		}
+	}
+	n := len(base) + len(cf)
 	for _, f := range ff {
 		n += len(f)
 	}
 	if n == 0 {
-		return nil
+		return nil, m
 	}
 
-	fields := getFields()
-	for k, v := range cf {
-		addField(fields, k, v)
+	fields = p.getFields()
+	for k, v := range base {
+		addField(fields, norm, k, v)
+	}
+	if group != "" && len(cf) > 0 {
+		g := p.getFields()
+		addFields(g, norm, maxFrames, cf, &m)
+		if len(*g) > 0 {
+			addField(fields, norm, group, groupFields(g))
+		}
+		p.putFields(g)
+	} else {
+		addFields(fields, norm, maxFrames, cf, &m)
 	}
 	for _, f := range ff {
-		for k, v := range f {
-			addField(fields, k, v)
+		addFields(fields, norm, maxFrames, f, &m)
+	}
+	return fields, m
+}
+
+// addFields adds a field set to fields, recording the options requested with
+// marker fields in m. Errors logged with Cause are expanded, see addCause.
+func addFields(fields *[]Field, norm func(string) string, maxFrames int, f F, m *markers) {
+	for k, v := range f {
+		switch v := v.(type) {
+		case causeError:
+			addCause(fields, norm, maxFrames, k, v.err)
+		case stackMarker:
+			m.stack = true
+		case callerSkip:
+			m.skip += int(v)
+		case entryTime:
+			m.at = time.Time(v)
+		default:
+			addField(fields, norm, k, v)
 		}
 	}
-	return fields
 }
 
-func addField(f *[]Field, key string, val any) {
+// keyHashLen is the length of the suffix truncateKey adds: an underscore and
+// 8 hex digits.
+const keyHashLen = 9
+
+// truncateKey cuts a key longer than n bytes on a rune boundary and suffixes
+// it with the FNV-1a hash of the whole key. Limits too short to fit the suffix
+// leave only a prefix of the hash.
+func truncateKey(key string, n int) string {
+	if len(key) <= n {
+		return key
+	}
+	h := uint32(2166136261)
+	for i := range len(key) {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	var suffix [keyHashLen]byte
+	suffix[0] = '_'
+	const digits = "0123456789abcdef"
+	for i := keyHashLen - 1; i > 0; i-- {
+		suffix[i] = digits[h&0xf]
+		h >>= 4
+	}
+	if n < keyHashLen {
+		return string(suffix[1 : n+1])
+	}
+
+	cut := n - keyHashLen
+	for cut > 0 && !utf8.RuneStart(key[cut]) {
+		cut--
+	}
+	return key[:cut] + string(suffix[:])
+}
+
+// groupFields returns fields as a map encoders write as an object.
+func groupFields(fields *[]Field) map[string]any {
+	g := make(map[string]any, len(*fields))
+	for _, f := range *fields {
+		g[f.Key] = f.Value
+	}
+	return g
+}
+
+func addField(f *[]Field, norm func(string) string, key string, val any) {
+	if norm != nil {
+		key = norm(key)
+	}
+
 	// Update existing entry if exists
 	for i := range *f {
 		if (*f)[i].Key == key {
@@ -52,44 +217,187 @@ func addField(f *[]Field, key string, val any) {
 	(*f) = append(*f, Field{key, val})
 }
 
-func sortFields(f []Field) {
-	if len(f) > 1 {
-		insertionSort(f)
+// redactFields applies the redactor to string field values, the messages of
+// errors, the output of Stringers, and strings in slices and maps.
+func redactFields(fields *[]Field, redact func(string) string) {
+	if fields == nil {
+		return
+	}
+	for i, f := range *fields {
+		// Only box changed values again, saving an allocation
+		if r, ok := redactValue(f.Value, redact); ok {
+			(*fields)[i].Value = r
+		}
 	}
 }
 
-// insertionSort is great for small slices. Using this custom function instead
-// of sort.Slice() reduces the number of allocations to zero.
-func insertionSort(f []Field) {
-	for i := 1; i < len(f); i++ {
-		for j := i; j > 0 && f[j].Key < f[j-1].Key; j-- {
-			f[j], f[j-1] = f[j-1], f[j]
+// redactValue applies the redactor to a value and reports whether it changed.
+// LogValuers are replaced with their redacted values, errors and Stringers with
+// their redacted strings. Slices and maps are copied before they are changed,
+// as they belong to the caller.
+func redactValue(val any, redact func(string) string) (any, bool) {
+	switch v := val.(type) {
+	case string:
+		r := redact(v)
+		return r, r != v
+	case time.Time, time.Duration:
+		// Stringers that encoders format themselves
+		return val, false
+	case LogValuer:
+		// Checked before errors and Stringers, which could reveal values that
+		// LogValue masks
+		if isNil(v) {
+			return val, false
+		}
+		return redactValue(ResolveLogValue(v), redact)
+	case error:
+		if isNil(v) {
+			return val, false
+		}
+		s := v.Error()
+		r := redact(s)
+		return r, r != s
+	case fmt.Stringer:
+		if isNil(v) {
+			return val, false
 		}
+		s := v.String()
+		r := redact(s)
+		return r, r != s
+	case []string:
+		return redactSlice(v, redact)
+	case []any:
+		return redactSlice(v, redact)
+	case map[string]string:
+		return redactMap(v, redact)
+	case map[string]any:
+		return redactMap(v, redact)
+	case F:
+		return redactMap(v, redact)
+	default:
+		return val, false
 	}
 }
 
-//
-// Fields pool
-//
+func redactSlice[S ~[]V, V any](s S, redact func(string) string) (any, bool) {
+	var out S
+	for i, v := range s {
+		if r, ok := redactValue(v, redact); ok {
+			if out == nil {
+				out = slices.Clone(s)
+			}
+			out[i] = r.(V)
+		}
+	}
+	if out == nil {
+		return s, false
+	}
+	return out, true
+}
 
-// Fields are pooled to reduce allocations.
-// Preallocated slices of 20 fields should be enough for most cases, in worst
-// case the slice will grow.
-var fieldsPool = sync.Pool{
-	New: func() any {
-		fields := make([]Field, 0, 20)
-		return &fields
-	},
+func redactMap[M ~map[string]V, V any](m M, redact func(string) string) (any, bool) {
+	var out M
+	for k, v := range m {
+		if r, ok := redactValue(v, redact); ok {
+			if out == nil {
+				out = maps.Clone(m)
+			}
+			out[k] = r.(V)
+		}
+	}
+	if out == nil {
+		return m, false
+	}
+	return out, true
 }
 
-func getFields() *[]Field {
-	return fieldsPool.Get().(*[]Field)
+// SnakeCaseKeys is a key normalizer that converts keys to snake case, e.g.
+// "Task ID" and "deviceID" become "task_id" and "device_id". Characters other
+// than ASCII letters and digits are treated as word separators.
+func SnakeCaseKeys(key string) string {
+	if isSnakeCase(key) {
+		return key
+	}
+
+	b := make([]byte, 0, len(key)+4)
+	sep := false // Separator is pending
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'A' <= c && c <= 'Z':
+			// Start a new word at the beginning of a capitalized word or an
+			// acronym, e.g. "deviceID" and "HTTPStatus"
+			if i > 0 && (isLowerOrDigit(key[i-1]) ||
+				isUpper(key[i-1]) && i+1 < len(key) && isLowerOrDigit(key[i+1])) {
+				sep = true
+			}
+			c += 'a' - 'A'
+		case isLowerOrDigit(c):
+		default:
+			sep = true
+			continue
+		}
+		if sep && len(b) > 0 {
+			b = append(b, '_')
+		}
+		sep = false
+		b = append(b, c)
+	}
+	return string(b)
 }
 
-func putFields(fields *[]Field) {
-	if fields == nil {
-		return
+func isSnakeCase(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if !isLowerOrDigit(key[i]) && key[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isLowerOrDigit(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9'
+}
+
+func isUpper(c byte) bool {
+	return 'A' <= c && c <= 'Z'
+}
+
+// sortedKeys returns the keys of a map value sorted, making the output stable.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// maxInsertionSort is the number of fields above which pattern-defeating
+// quicksort outperforms insertion sort, see BenchmarkSortFields.
+const maxInsertionSort = 12
+
+// sortFields sorts fields by key. The sort is not stable, which doesn't matter
+// since makeFields never produces duplicate keys.
+func sortFields(f []Field) {
+	switch {
+	case len(f) > maxInsertionSort:
+		slices.SortFunc(f, compareFields)
+	case len(f) > 1:
+		insertionSort(f)
+	}
+}
+
+func compareFields(a, b Field) int {
+	return strings.Compare(a.Key, b.Key)
+}
+
+// insertionSort is great for small slices. Using this custom function instead
+// of sort.Slice() reduces the number of allocations to zero.
+func insertionSort(f []Field) {
+	for i := 1; i < len(f); i++ {
+		for j := i; j > 0 && f[j].Key < f[j-1].Key; j-- {
+			f[j], f[j-1] = f[j-1], f[j]
+		}
 	}
-	*fields = (*fields)[:0] // Reset
-	fieldsPool.Put(fields)
 }
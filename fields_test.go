@@ -1,8 +1,11 @@
 package blip
 
 import (
+	"bytes"
 	"context"
+	"math/rand/v2"
 	"slices"
+	"strconv"
 	"testing"
 )
 
@@ -12,12 +15,12 @@ func TestMakeFields(t *testing.T) {
 		"a": 1,
 		"b": 2,
 	})
-	fields := makeFields(ctx, []F{
+	fields, _ := makeFields(ctx, LevelInfo, sharedPools, nil, defaultMaxFrames, "", nil, []F{
 		{"c": 3, "a": -1},
 		{"c": 4},
 	})
 	sortFields(*fields)
-	defer putFields(fields)
+	defer sharedPools.putFields(fields)
 
 	exp := []Field{
 		{"a", -1},
@@ -31,7 +34,7 @@ func TestMakeFields(t *testing.T) {
 
 func TestMakeFieldsEmpty(t *testing.T) {
 	ctx := context.Background()
-	fields := makeFields(ctx, []F{})
+	fields, _ := makeFields(ctx, LevelInfo, sharedPools, nil, defaultMaxFrames, "", nil, []F{})
 	if fields != nil {
 		t.Errorf("expected nil, got %v", fields)
 	}
@@ -42,11 +45,11 @@ func TestMakeFieldsOnlyContext(t *testing.T) {
 	ctx = ContextWithFields(ctx, F{
 		"a": 1,
 	})
-	fields := makeFields(ctx, nil)
+	fields, _ := makeFields(ctx, LevelInfo, sharedPools, nil, defaultMaxFrames, "", nil, nil)
 	if fields == nil {
 		t.Fatal("expected non-nil fields")
 	}
-	defer putFields(fields)
+	defer sharedPools.putFields(fields)
 
 	exp := []Field{
 		{"a", 1},
@@ -56,23 +59,184 @@ func TestMakeFieldsOnlyContext(t *testing.T) {
 	}
 }
 
+func TestMakeFieldsBase(t *testing.T) {
+	ctx := context.Background()
+	ctx = ContextWithFields(ctx, F{"b": 2})
+	fields, _ := makeFields(ctx, LevelInfo, sharedPools, nil, defaultMaxFrames, "", F{"a": 1, "b": 1, "c": 1}, []F{{"c": 3}})
+	sortFields(*fields)
+	defer sharedPools.putFields(fields)
+
+	exp := []Field{
+		{"a", 1},
+		{"b", 2},
+		{"c", 3},
+	}
+	if !slices.Equal(exp, *fields) {
+		t.Errorf("expected %v, got %v", exp, *fields)
+	}
+}
+
+func TestMakeFieldsDedup(t *testing.T) {
+	ctx := ContextWithFields(context.Background(), F{"task_id": 1})
+	fields, _ := makeFields(ctx, LevelInfo, sharedPools, nil, defaultMaxFrames, "", F{"task_id": 0}, []F{
+		{"task_id": 2},
+		{"task_id": 3, "status": "done"},
+	})
+	defer sharedPools.putFields(fields)
+
+	var n int
+	for _, f := range *fields {
+		if f.Key == "task_id" {
+			n++
+			if f.Value != 3 {
+				t.Errorf("expected the last value to win, got %v", f.Value)
+			}
+		}
+	}
+	if n != 1 || len(*fields) != 2 {
+		t.Errorf("expected a single task_id field, got %v", *fields)
+	}
+}
+
 func TestSortFields(t *testing.T) {
+	// Keys are unique, see TestMakeFieldsDedup
 	fields := []Field{
 		{"b", 2},
 		{"a", 1},
-		{"d", 5},
 		{"f", 5},
-		{"d", 5},
+		{"d", 4},
 	}
 	sortFields(fields)
 	exp := []Field{
 		{"a", 1},
 		{"b", 2},
-		{"d", 5},
-		{"d", 5},
+		{"d", 4},
 		{"f", 5},
 	}
 	if !slices.Equal(exp, fields) {
 		t.Errorf("expected %v, got %v", exp, fields)
 	}
 }
+
+func TestSortFieldsMany(t *testing.T) {
+	var fields []Field
+	for _, i := range rand.New(rand.NewPCG(1, 2)).Perm(4 * maxInsertionSort) {
+		fields = append(fields, Field{"key_" + strconv.Itoa(i), i})
+	}
+	exp := slices.Clone(fields)
+	insertionSort(exp)
+
+	sortFields(fields)
+	if !slices.Equal(exp, fields) {
+		t.Errorf("expected %v, got %v", exp, fields)
+	}
+}
+
+func TestMakeFieldsKeyNormalizer(t *testing.T) {
+	ctx := context.Background()
+	ctx = ContextWithFields(ctx, F{"task_id": 1, "deviceID": "a"})
+	fields, _ := makeFields(ctx, LevelInfo, sharedPools, SnakeCaseKeys, defaultMaxFrames, "", nil, []F{{"Task ID": 2}})
+	sortFields(*fields)
+	defer sharedPools.putFields(fields)
+
+	exp := []Field{
+		{"device_id", "a"},
+		{"task_id", 2},
+	}
+	if !slices.Equal(exp, *fields) {
+		t.Errorf("expected %v, got %v", exp, *fields)
+	}
+}
+
+func TestSnakeCaseKeys(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"task_id", "task_id"},
+		{"Task ID", "task_id"},
+		{"TaskID", "task_id"},
+		{"deviceID", "device_id"},
+		{"HTTPStatus", "http_status"},
+		{"task-id", "task_id"},
+		{"task.id", "task_id"},
+		{"  Task  ID  ", "task_id"},
+		{"ipv4Addr", "ipv4_addr"},
+		{"Größe", "gr_e"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := SnakeCaseKeys(tt.in); got != tt.exp {
+			t.Errorf("%q: expected %q, got %q", tt.in, tt.exp, got)
+		}
+	}
+}
+
+func BenchmarkSortFields(b *testing.B) {
+	for _, n := range []int{8, 12, 16, 24, 32, 48} {
+		// Fields come from maps, so their order is random
+		src := make([]Field, n)
+		for i, j := range rand.New(rand.NewPCG(1, 2)).Perm(n) {
+			src[i] = Field{Key: "key_" + strconv.Itoa(j), Value: j}
+		}
+		f := make([]Field, n)
+		b.Run("insertion/"+strconv.Itoa(n), func(b *testing.B) {
+			for range b.N {
+				copy(f, src)
+				insertionSort(f)
+			}
+		})
+		b.Run("pdqsort/"+strconv.Itoa(n), func(b *testing.B) {
+			for range b.N {
+				copy(f, src)
+				slices.SortFunc(f, compareFields)
+			}
+		})
+	}
+}
+
+func TestTruncateKey(t *testing.T) {
+	const long = "request_headers_x_forwarded_for"
+	short := truncateKey(long, 20)
+	if len(short) != 20 || short[:11] != "request_hea" || short[11] != '_' {
+		t.Errorf("expected a 20 byte key with a hash suffix, got %q", short)
+	}
+	if again := truncateKey(long, 20); again != short {
+		t.Errorf("expected truncation to be deterministic, got %q and %q", short, again)
+	}
+	// Keys with the same prefix remain distinct
+	if other := truncateKey("request_headers_x_forwarded_host", 20); other == short {
+		t.Errorf("expected distinct keys, got %q for both", other)
+	}
+
+	if key := truncateKey("task_id", 20); key != "task_id" {
+		t.Errorf("expected short keys to be unchanged, got %q", key)
+	}
+	if key := truncateKey(long, 4); len(key) != 4 {
+		t.Errorf("expected a 4 byte hash prefix, got %q", key)
+	}
+	// Multi-byte runes are not split
+	if key := truncateKey("ключ_запроса", 12); len(key) != 11 || key[:2] != "к" {
+		t.Errorf("expected the cut on a rune boundary, got %q", key)
+	}
+}
+
+func TestMaxKeyLen(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{
+		Output:        &buf,
+		Encoder:       &ConsoleEncoder{SortFields: true},
+		KeyNormalizer: SnakeCaseKeys,
+		MaxKeyLen:     16,
+	})
+	logger.Info(context.Background(), "Request", F{
+		"Request Header Accept":   "text/html",
+		"Request Header Accepted": true,
+		"status":                  200,
+	})
+	// Both keys are cut to "request", the hashes tell them apart
+	exp := "INFO Request  request_c69a3f56=true request_e56e4a17=text/html status=200\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
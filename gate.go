@@ -0,0 +1,39 @@
+package blip
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Gate limits how often a call site logs, e.g. a health check that runs
+// every second but should only log once a minute:
+//
+//	var healthy = blip.Every(time.Minute)
+//
+//	if healthy.Allow() {
+//		logger.Info(ctx, "Health check passed")
+//	}
+//
+// Unlike a sampler, a gate is not tied to a logger or level, each call site
+// that is limited declares its own. It is safe for concurrent use.
+type Gate struct {
+	interval time.Duration
+	next     atomic.Int64 // Unix nanoseconds
+}
+
+// Every creates a gate that allows at most one call per interval.
+func Every(interval time.Duration) *Gate {
+	return &Gate{interval: interval}
+}
+
+// Allow reports whether the interval has passed since the last allowed call.
+// The first call is always allowed.
+func (g *Gate) Allow() bool {
+	now := timeNow().UnixNano()
+	next := g.next.Load()
+	if now < next {
+		return false
+	}
+	// Only one of the concurrent callers wins
+	return g.next.CompareAndSwap(next, now+int64(g.interval))
+}
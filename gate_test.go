@@ -0,0 +1,34 @@
+package blip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGate(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	gate := Every(time.Minute)
+	steps := []struct {
+		at  time.Duration
+		exp bool
+	}{
+		{0, true},
+		{0, false},
+		{time.Second, false},
+		{59 * time.Second, false},
+		{time.Minute, true},
+		{time.Minute + 30*time.Second, false},
+		{5 * time.Minute, true},
+		{5*time.Minute + time.Second, false},
+	}
+	start := now
+	for _, s := range steps {
+		now = start.Add(s.at)
+		if got := gate.Allow(); got != s.exp {
+			t.Errorf("at %v: expected %t, got %t", s.at, s.exp, got)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/localhots/blip/grpclog
+
+go 1.23.4
+
+require (
+	github.com/localhots/blip v0.0.0
+	google.golang.org/grpc v1.66.2
+)
+
+require (
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240604185151-ef581f913117 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
+
+replace github.com/localhots/blip => ../
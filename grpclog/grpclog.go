@@ -0,0 +1,106 @@
+// Package grpclog provides server interceptors for logging gRPC calls. It is
+// kept separate so that the blip package does not depend on gRPC.
+//
+//	srv := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(grpclog.UnaryServerInterceptor(logger)),
+//		grpc.ChainStreamInterceptor(grpclog.StreamServerInterceptor(logger)),
+//	)
+//
+// Every call gets a trace ID attached to its context, so entries logged by the
+// handler can be correlated with the entry logged for the call. Calls are
+// logged with their method, status code, duration, and peer address, at a
+// level that depends on the status code, see CodeLevel.
+package grpclog
+
+import (
+	"context"
+	"time"
+
+	"github.com/localhots/blip"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader is the response header that carries the trace ID of the
+// call. Set it to an empty string to omit the header.
+var RequestIDHeader = "x-request-id"
+
+// CodeLevel returns the level calls finished with the given status code are
+// logged at. By default calls that succeeded are logged at the info level and
+// calls that failed at the error level.
+var CodeLevel = func(code codes.Code) blip.Level {
+	if code == codes.OK {
+		return blip.LevelInfo
+	}
+	return blip.LevelError
+}
+
+// UnaryServerInterceptor returns an interceptor that attaches a trace ID to
+// the context of every unary call and logs the call once it is handled.
+func UnaryServerInterceptor(l *blip.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx = contextWithRequestID(ctx)
+		resp, err := handler(ctx, req)
+		logCall(ctx, l, info.FullMethod, err, time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that attaches a trace ID to
+// the context of every streaming call and logs the call once it is handled.
+func StreamServerInterceptor(l *blip.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := contextWithRequestID(ss.Context())
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, l, info.FullMethod, err, time.Since(start))
+		return err
+	}
+}
+
+// Call returns the method, status code, duration, and peer address of a call.
+// The peer address is omitted if the context doesn't have one.
+func Call(ctx context.Context, method string, code codes.Code, dur time.Duration) blip.F {
+	f := blip.F{
+		"method":   method,
+		"code":     code.String(),
+		"duration": dur,
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		f["peer"] = p.Addr.String()
+	}
+	return f
+}
+
+func logCall(ctx context.Context, l *blip.Logger, method string, err error, dur time.Duration) {
+	code := status.Code(err)
+	fields := []blip.F{Call(ctx, method, code, dur)}
+	if err != nil {
+		fields = append(fields, blip.Cause(err))
+	}
+	l.Log(ctx, CodeLevel(code), "gRPC call", fields...)
+}
+
+func contextWithRequestID(ctx context.Context) context.Context {
+	ctx = blip.ContextWithTraceID(ctx)
+	if RequestIDHeader != "" {
+		// Fails if the context has no server transport stream, e.g. when the
+		// interceptor is called directly, there is nobody to send it to then
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, blip.TraceIDFromContext(ctx)))
+	}
+	return ctx
+}
+
+// serverStream overrides the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,138 @@
+package grpclog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/localhots/blip"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func newLogger(buf *bytes.Buffer) *blip.Logger {
+	enc := blip.NewJSONEncoder()
+	enc.TimeFormat = ""
+	return blip.New(blip.Config{Output: buf, Encoder: enc, StackTraceLevel: blip.LevelPanic})
+}
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+	}
+	return entry
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1234},
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: "/jobs.Jobs/Get"}
+
+	tests := []struct {
+		name  string
+		err   error
+		code  string
+		level string
+	}{
+		{"ok", nil, "OK", "info"},
+		{"not found", status.Error(codes.NotFound, "no such job"), "NotFound", "error"},
+		{"plain error", errors.New("boom"), "Unknown", "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var traceID string
+			_, err := UnaryServerInterceptor(newLogger(&buf))(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+				traceID = blip.TraceIDFromContext(ctx)
+				return nil, tt.err
+			})
+			if err != tt.err {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+			if traceID == "" {
+				t.Fatal("expected a trace ID in the handler context")
+			}
+
+			entry := decode(t, &buf)
+			exp := map[string]any{
+				"level":    tt.level,
+				"message":  "gRPC call",
+				"method":   "/jobs.Jobs/Get",
+				"code":     tt.code,
+				"peer":     "192.0.2.1:1234",
+				"trace_id": traceID,
+			}
+			for k, v := range exp {
+				if entry[k] != v {
+					t.Errorf("expected %s=%v, got %v", k, v, entry[k])
+				}
+			}
+			if _, ok := entry["duration"]; !ok {
+				t.Error("expected a duration field")
+			}
+			if _, ok := entry["error"]; ok != (tt.err != nil) {
+				t.Errorf("expected error field to be present: %t, got %v", tt.err != nil, entry["error"])
+			}
+		})
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	info := &grpc.StreamServerInfo{FullMethod: "/jobs.Jobs/Watch", IsServerStream: true}
+	ss := &fakeStream{ctx: context.Background()}
+
+	var traceID string
+	err := StreamServerInterceptor(newLogger(&buf))(nil, ss, info, func(srv any, ss grpc.ServerStream) error {
+		traceID = blip.TraceIDFromContext(ss.Context())
+		return status.Error(codes.Unavailable, "shutting down")
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+	if traceID == "" {
+		t.Fatal("expected a trace ID in the stream context")
+	}
+
+	entry := decode(t, &buf)
+	if entry["level"] != "error" || entry["code"] != "Unavailable" || entry["trace_id"] != traceID {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if _, ok := entry["peer"]; ok {
+		t.Errorf("expected no peer field, got %v", entry["peer"])
+	}
+}
+
+func TestCodeLevel(t *testing.T) {
+	defer func(fn func(codes.Code) blip.Level) { CodeLevel = fn }(CodeLevel)
+	CodeLevel = func(code codes.Code) blip.Level {
+		if code == codes.NotFound {
+			return blip.LevelWarn
+		}
+		return blip.LevelInfo
+	}
+
+	var buf bytes.Buffer
+	info := &grpc.UnaryServerInfo{FullMethod: "/jobs.Jobs/Get"}
+	_, _ = UnaryServerInterceptor(newLogger(&buf))(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "no such job")
+	})
+	if entry := decode(t, &buf); entry["level"] != "warn" {
+		t.Errorf("expected warn, got %v", entry["level"])
+	}
+}
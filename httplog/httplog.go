@@ -0,0 +1,127 @@
+// Package httplog provides fields and a middleware for logging HTTP requests.
+// It is kept separate so that the blip package does not depend on net/http.
+//
+//	handler := httplog.Middleware(logger, mux)
+//	http.ListenAndServe(":8080", handler)
+//
+// Every request gets a trace ID attached to its context, so entries logged by
+// the handler can be correlated with the entry logged for the request. The
+// fields can also be used on their own:
+//
+//	logger.Info(ctx, "Proxying request", httplog.Request(r))
+package httplog
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/localhots/blip"
+)
+
+// Headers is the list of request headers included by Request. Header values
+// are logged under snake case keys, e.g. User-Agent becomes user_agent.
+var Headers = []string{"User-Agent", "Referer"}
+
+// RequestIDHeader is the response header that carries the trace ID of the
+// request. Set it to an empty string to omit the header.
+var RequestIDHeader = "X-Request-Id"
+
+// Request returns the method, path, and remote address of a request, along
+// with the values of the headers listed in Headers. Headers that are not set
+// are omitted.
+func Request(r *http.Request) blip.F {
+	f := blip.F{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}
+	for _, h := range Headers {
+		if v := r.Header.Get(h); v != "" {
+			f[headerKey(h)] = v
+		}
+	}
+	return f
+}
+
+// Response returns the status code and duration of a response.
+func Response(status int, dur time.Duration) blip.F {
+	return blip.F{
+		"status":   status,
+		"duration": dur,
+	}
+}
+
+// Middleware returns a handler that attaches a trace ID to the context of
+// every request and logs the request once next has handled it. Responses with
+// a 5xx status are logged at the error level, the rest at the info level.
+func Middleware(l *blip.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := blip.ContextWithTraceID(r.Context())
+		if RequestIDHeader != "" {
+			w.Header().Set(RequestIDHeader, blip.TraceIDFromContext(ctx))
+		}
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		lev := blip.LevelInfo
+		if rw.status >= http.StatusInternalServerError {
+			lev = blip.LevelError
+		}
+		l.Log(ctx, lev, "HTTP request", Request(r), Response(rw.status, time.Since(start)))
+	})
+}
+
+// headerKey converts a canonical header name to a field key.
+func headerKey(h string) string {
+	return strings.ReplaceAll(strings.ToLower(h), "-", "_")
+}
+
+// responseWriter records the status code written by a handler.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher for handlers streaming responses. It does
+// nothing if the underlying writer can't be flushed.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for handlers taking over the connection,
+// e.g. to serve WebSockets.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush it.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
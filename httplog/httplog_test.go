@@ -0,0 +1,128 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/localhots/blip"
+)
+
+func TestRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/users/42?full=1", nil)
+	r.Header.Set("User-Agent", "curl/8.0")
+
+	f := Request(r)
+	exp := blip.F{
+		"method":      "GET",
+		"path":        "/users/42",
+		"remote_addr": "192.0.2.1:1234",
+		"user_agent":  "curl/8.0",
+	}
+	if len(f) != len(exp) {
+		t.Errorf("expected %v, got %v", exp, f)
+	}
+	for k, v := range exp {
+		if f[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, f[k])
+		}
+	}
+}
+
+func TestResponse(t *testing.T) {
+	f := Response(http.StatusNotFound, 3*time.Millisecond)
+	if f["status"] != http.StatusNotFound || f["duration"] != 3*time.Millisecond {
+		t.Errorf("unexpected fields: %v", f)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	enc := blip.NewJSONEncoder()
+	enc.TimeFormat = ""
+	logger := blip.New(blip.Config{Output: &buf, Encoder: enc, StackTraceLevel: blip.LevelPanic})
+
+	var traceID string
+	handler := Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = blip.TraceIDFromContext(r.Context())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "Try again later")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", nil))
+	if traceID == "" {
+		t.Fatal("expected a trace ID in the request context")
+	}
+	if id := rec.Header().Get(RequestIDHeader); id != traceID {
+		t.Errorf("expected request ID header %q, got %q", traceID, id)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+	}
+	exp := map[string]any{
+		"level":       "error",
+		"message":     "HTTP request",
+		"method":      "POST",
+		"path":        "/jobs",
+		"remote_addr": "192.0.2.1:1234",
+		"status":      float64(http.StatusServiceUnavailable),
+		"trace_id":    traceID,
+	}
+	for k, v := range exp {
+		if entry[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("expected a duration field")
+	}
+}
+
+func TestMiddlewareDefaultStatus(t *testing.T) {
+	var buf bytes.Buffer
+	logger := blip.New(blip.Config{Output: &buf, Encoder: &blip.JSONEncoder{KeyLevel: "level"}})
+	handler := Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "OK")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !bytes.Contains(buf.Bytes(), []byte(`"level":"info"`)) || !bytes.Contains(buf.Bytes(), []byte(`"status":200`)) {
+		t.Errorf("expected an info entry with status 200, got %s", buf.String())
+	}
+}
+
+func TestMiddlewareFlushHijack(t *testing.T) {
+	logger := blip.New(blip.Config{Output: io.Discard})
+	var flushed, hijackable bool
+	handler := Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+			flushed = true
+		}
+		_, hijackable = w.(http.Hijacker)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !flushed || !rec.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if !hijackable {
+		t.Error("expected the writer to implement http.Hijacker")
+	}
+
+	// The recorder can't be hijacked
+	handler = Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("expected http.ErrNotSupported, got %v", err)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
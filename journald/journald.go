@@ -0,0 +1,216 @@
+//go:build linux
+
+// Package journald provides an encoder and a writer that send log entries to
+// the systemd journal using its native protocol. Fields are sent as journal
+// fields, e.g. "task_id" becomes TASK_ID, so entries can be filtered with
+// journalctl:
+//
+//	w, err := journald.NewWriter()
+//	if err != nil {
+//		return err
+//	}
+//	logger := blip.New(blip.Config{
+//		Output:  w,
+//		Encoder: journald.NewEncoder(),
+//	})
+//
+// Both are only available on Linux.
+package journald
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/localhots/blip"
+)
+
+// SocketPath is the path of the journal socket.
+var SocketPath = "/run/systemd/journal/socket"
+
+// Syslog priorities, see syslog(3).
+const (
+	priorityCrit    = 2
+	priorityErr     = 3
+	priorityWarning = 4
+	priorityInfo    = 6
+	priorityDebug   = 7
+)
+
+// Encoder is an encoder that encodes log entries as journal messages. The
+// message is sent as MESSAGE, the level as PRIORITY, and the stack trace as
+// STACK_TRACE. The time is assigned by the journal.
+type Encoder struct{}
+
+var _ blip.EntryEncoder = (*Encoder)(nil)
+
+// NewEncoder creates a new journal encoder.
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Encode encodes a whole entry with its stack trace.
+func (e *Encoder) Encode(buf *blip.Buffer, entry blip.Entry) {
+	e.EncodeLevel(buf, entry.Level)
+	e.EncodeMessage(buf, entry.Message)
+	e.EncodeFields(buf, entry.Level, entry.Fields)
+	if entry.StackTrace != "" {
+		writeVar(buf, "STACK_TRACE", entry.StackTrace)
+	}
+}
+
+// Start writes the beginning of the log message.
+func (e *Encoder) Start(_ *blip.Buffer) {}
+
+// EncodeTime encodes the time of the log message. The journal records its own
+// timestamps, so the time is not written.
+func (e *Encoder) EncodeTime(_ *blip.Buffer) {}
+
+// EncodeLevel encodes the log level of the message as a syslog priority.
+func (e *Encoder) EncodeLevel(buf *blip.Buffer, lev blip.Level) {
+	buf.WriteString("PRIORITY=")
+	buf.WriteInt(int64(Priority(lev)))
+	buf.WriteBytes('\n')
+}
+
+// EncodeMessage encodes the log message.
+func (e *Encoder) EncodeMessage(buf *blip.Buffer, msg string) {
+	writeVar(buf, "MESSAGE", msg)
+}
+
+// EncodeFields encodes the fields of the log message. Field keys are converted
+// to valid journal field names.
+func (e *Encoder) EncodeFields(buf *blip.Buffer, _ blip.Level, fields *[]blip.Field) {
+	if fields == nil {
+		return
+	}
+	for _, f := range *fields {
+		name := FieldName(f.Key)
+		if name == "" {
+			continue
+		}
+		writeVar(buf, name, formatValue(f.Value))
+	}
+}
+
+// EncodeStackTrace encodes the stack trace of the log message.
+func (e *Encoder) EncodeStackTrace(buf *blip.Buffer, skip int) {
+	writeVar(buf, "STACK_TRACE", blip.StackTrace(skip+1))
+}
+
+// End writes the end of the log message.
+func (e *Encoder) End(_ *blip.Buffer) {}
+
+// Priority returns the syslog priority of a level.
+func Priority(lev blip.Level) int {
+	switch {
+	case lev <= blip.LevelDebug:
+		return priorityDebug
+	case lev == blip.LevelInfo:
+		return priorityInfo
+	case lev == blip.LevelWarn:
+		return priorityWarning
+	case lev == blip.LevelError:
+		return priorityErr
+	default:
+		return priorityCrit
+	}
+}
+
+// FieldName converts a field key to a journal field name, which consists of
+// uppercase letters, digits, and underscores, and doesn't start with an
+// underscore or a digit. Other characters are replaced with underscores. It
+// returns an empty string if no valid name remains.
+func FieldName(key string) string {
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			c -= 'a' - 'A'
+		case 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		default:
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	name := strings.TrimLeft(string(b), "_0123456789")
+	// Names are limited to 64 characters
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// writeVar writes a journal field. Values containing newlines are written in
+// the binary form, prefixed with their size.
+func writeVar(buf *blip.Buffer, name, val string) {
+	buf.WriteString(name)
+	if strings.IndexByte(val, '\n') < 0 {
+		buf.WriteBytes('=')
+		buf.WriteString(val)
+		buf.WriteBytes('\n')
+		return
+	}
+	buf.WriteBytes('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(val)))
+	buf.WriteBytes(size[:]...)
+	buf.WriteString(val)
+	buf.WriteBytes('\n')
+}
+
+func formatValue(val any) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case blip.LogValuer:
+		return formatValue(blip.ResolveLogValue(v))
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Writer sends log entries encoded with Encoder to the journal, one datagram
+// per entry. It is safe for concurrent use.
+type Writer struct {
+	conn *net.UnixConn
+}
+
+// NewWriter connects to the journal socket at SocketPath.
+func NewWriter() (*Writer, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: SocketPath, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("connect to journal: %w", err)
+	}
+	return &Writer{conn: conn}, nil
+}
+
+// Write sends an entry to the journal. Entries larger than the maximum
+// datagram size of the socket are rejected by the kernel.
+func (w *Writer) Write(b []byte) (int, error) {
+	return w.conn.Write(b)
+}
+
+// Close closes the connection to the journal.
+func (w *Writer) Close() error {
+	return w.conn.Close()
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package journald
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/localhots/blip"
+)
+
+func TestWriter(t *testing.T) {
+	defer func(path string) { SocketPath = path }(SocketPath)
+	SocketPath = filepath.Join(t.TempDir(), "socket")
+	journal, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: SocketPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer journal.Close()
+
+	w, err := NewWriter()
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	cfg := blip.DefaultConfig()
+	cfg.Output = w
+	cfg.Encoder = NewEncoder()
+	cfg.StackTraceLevel = blip.LevelFatal
+	logger := blip.New(cfg)
+	logger.Error(context.Background(), "Task failed", blip.Cause(errors.New("line 1\nline 2")), blip.F{
+		"task_id":   123,
+		"Device ID": "G4000E",
+	})
+
+	msg := make([]byte, 4096)
+	n, err := journal.Read(msg)
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	vars := parse(t, msg[:n])
+	exp := map[string]string{
+		"PRIORITY":  "3",
+		"MESSAGE":   "Task failed",
+		"ERROR":     "line 1\nline 2",
+		"TASK_ID":   "123",
+		"DEVICE_ID": "G4000E",
+	}
+	if len(vars) != len(exp) {
+		t.Errorf("expected %v, got %v", exp, vars)
+	}
+	for k, v := range exp {
+		if vars[k] != v {
+			t.Errorf("expected %s=%q, got %q", k, v, vars[k])
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	exp := map[blip.Level]int{
+		blip.LevelTrace: 7,
+		blip.LevelDebug: 7,
+		blip.LevelInfo:  6,
+		blip.LevelWarn:  4,
+		blip.LevelError: 3,
+		blip.LevelPanic: 2,
+		blip.LevelFatal: 2,
+	}
+	for lev, p := range exp {
+		if got := Priority(lev); got != p {
+			t.Errorf("expected priority %d for %s, got %d", p, lev, got)
+		}
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	tests := map[string]string{
+		"task_id":   "TASK_ID",
+		"Device ID": "DEVICE_ID",
+		"_private":  "PRIVATE",
+		"2fa":       "FA",
+		"__":        "",
+	}
+	for key, exp := range tests {
+		if got := FieldName(key); got != exp {
+			t.Errorf("expected %q for %q, got %q", exp, key, got)
+		}
+	}
+}
+
+// parse parses a message in the native journal protocol.
+func parse(t *testing.T, msg []byte) map[string]string {
+	t.Helper()
+	vars := map[string]string{}
+	for len(msg) > 0 {
+		i := bytes.IndexByte(msg, '\n')
+		if i < 0 {
+			t.Fatalf("unterminated field: %q", msg)
+		}
+		line := msg[:i]
+		msg = msg[i+1:]
+		if k, v, ok := bytes.Cut(line, []byte{'='}); ok {
+			vars[string(k)] = string(v)
+			continue
+		}
+		size := binary.LittleEndian.Uint64(msg)
+		vars[string(line)] = string(msg[8 : 8+size])
+		msg = msg[8+size+1:]
+	}
+	return vars
+}
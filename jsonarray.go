@@ -0,0 +1,105 @@
+package blip
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrClosed is returned when writing to a closed JSONArrayWriter or logging
+// with a closed Logger.
+var ErrClosed = errors.New("blip: closed")
+
+// JSONArrayWriter writes entries encoded by the JSON encoder as elements of a
+// single JSON array, one per line, for tools that load a whole file as an
+// array rather than newline-delimited objects:
+//
+//	[
+//	{"level":"info","message":"First"},
+//	{"level":"info","message":"Second"}
+//	]
+//
+// The array is finalized by Close, an array without entries is written as
+// []. Every line of a write becomes an element, so a write must hold whole
+// entries, as written by the logger. It is safe for concurrent use and can be
+// used as the logger output.
+type JSONArrayWriter struct {
+	w      io.Writer
+	lock   sync.Mutex
+	n      int
+	closed bool
+}
+
+// NewJSONArrayWriter creates a writer that writes entries to w as elements of
+// a JSON array.
+func NewJSONArrayWriter(w io.Writer) *JSONArrayWriter {
+	return &JSONArrayWriter{w: w}
+}
+
+// Write implements the io.Writer interface. It writes every line as the next
+// element of the array, with its line ending removed, e.g. an entry and its
+// stack trace written on a separate line by the JSON encoder with
+// StackTraceLine. Empty lines are skipped.
+func (w *JSONArrayWriter) Write(b []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	var out []byte
+	n := w.n
+	for _, line := range bytes.Split(b, []byte{'\n'}) {
+		elem := bytes.TrimRight(line, "\r")
+		if len(elem) == 0 {
+			continue
+		}
+		if n == 0 {
+			out = append(out, '[', '\n')
+		} else {
+			out = append(out, ',', '\n')
+		}
+		out = append(out, elem...)
+		n++
+	}
+	if n == w.n {
+		return len(b), nil
+	}
+	// Write the separators along with the entries so that they are not split
+	// by a failed write
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	w.n = n
+	return len(b), nil
+}
+
+// Sync commits the written entries to stable storage if the underlying writer
+// supports that, like *os.File does.
+func (w *JSONArrayWriter) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if s, ok := w.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Close finalizes the array. Subsequent writes fail with ErrClosed. The
+// underlying writer is not closed.
+func (w *JSONArrayWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	end := "\n]\n"
+	if w.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
@@ -0,0 +1,98 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestJSONArrayWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONArrayWriter(&buf)
+	enc := NewJSONEncoder()
+	enc.TimeFormat = ""
+	logger := New(Config{Output: w, Encoder: enc})
+
+	const goroutines, entries = 4, 25
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range entries {
+				logger.Info(context.Background(), "Entry", F{"i": i})
+			}
+		}()
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	var out []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+	}
+	if len(out) != goroutines*entries {
+		t.Errorf("expected %d entries, got %d", goroutines*entries, len(out))
+	}
+
+	// Closing again is a no-op, writing fails
+	if err := w.Close(); err != nil {
+		t.Errorf("expected no error closing twice, got %v", err)
+	}
+	if _, err := w.Write([]byte("{}\n")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestJSONArrayWriterFormat(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONArrayWriter(&buf)
+	logger := New(Config{Output: w, Encoder: &JSONEncoder{KeyLevel: "level", KeyMessage: "message"}})
+	logger.Info(context.Background(), "First")
+	logger.Info(context.Background(), "Second")
+	_ = w.Close()
+
+	exp := "[\n" +
+		`{"level":"info","message":"First"},` + "\n" +
+		`{"level":"info","message":"Second"}` + "\n" +
+		"]\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestJSONArrayWriterStackTraceLine(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONArrayWriter(&buf)
+	enc := &JSONEncoder{KeyMessage: "message", KeySequence: "seq", KeyStackTrace: "stacktrace", StackTraceLine: true}
+	logger := New(Config{Output: w, Encoder: enc, IncludeSequence: true})
+	logger.Info(context.Background(), "Task started", Stack())
+	_ = w.Close()
+
+	var out []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v\nJSON: %s", err, buf.String())
+	}
+	if len(out) != 2 || out[0]["message"] != "Task started" || out[1]["stacktrace"] == nil {
+		t.Errorf("expected the entry and its stack trace as elements, got %v", out)
+	}
+}
+
+func TestJSONArrayWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONArrayWriter(&buf)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("expected an empty array, got %q", buf.String())
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Errorf("expected valid JSON, got %q", buf.String())
+	}
+}
@@ -10,17 +10,64 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"reflect"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 // Logger is a the main structure used to log messages.
 type Logger struct {
-	cfg  Config
-	enc  Encoder
+	cfg    Config
+	level  atomic.Int64
+	enc    Encoder
+	fields F
+	// norm normalizes field keys, see keyNormalizer
+	norm   func(string) string
+	pools  *pools
+	counts [LevelFatal + 1]atomic.Uint64
+	// warnedEmpty is set once an empty message is warned about
+	warnedEmpty atomic.Bool
+	once        onceSet
+	// schedule is set when the config has a level schedule
+	schedule *levelSchedule
+	// sink is shared with the loggers created with Named
+	*sink
+}
+
+// sink is the output of a logger and the state of writing to it, shared by a
+// logger and the loggers created from it with Named, so that they don't write
+// to the output concurrently.
+type sink struct {
 	lock sync.Mutex
+	out  io.Writer
+	subs []chan []byte
+	seq  atomic.Uint64
+	// discard is set when the output is Discard and there are no subscribers
+	discard atomic.Bool
+	// closed is set by Close, it is only set with the lock held so that
+	// writes checking it under the lock never follow the close
+	closed  atomic.Bool
+	dropped atomic.Uint64
+}
+
+func newSink(w io.Writer) *sink {
+	s := &sink{out: w}
+	s.updateDiscard()
+	return s
+}
+
+// updateDiscard sets the discard flag after the output or the subscribers
+// change. Must be called with the lock held.
+func (s *sink) updateDiscard() {
+	s.discard.Store(s.out == Discard && len(s.subs) == 0)
 }
 
 // Config is the configuration structure for the logger.
@@ -30,11 +77,154 @@ type Config struct {
 	Encoder         Encoder
 	StackTraceLevel Level
 	StackTraceSkip  int
+	// PanicOnPanicLevel makes Panic panic with the message after logging it,
+	// like log.Panic does. It is enabled by DefaultConfig. When disabled,
+	// Panic only logs the message.
+	PanicOnPanicLevel bool
+	// StackTraceMaxFrames limits the number of frames in a stack trace,
+	// including the stack traces of errors logged with Cause. Defaults to 32.
+	StackTraceMaxFrames int
+	// OnEmptyMessage controls how entries with an empty message are handled,
+	// by default they are logged as they are.
+	OnEmptyMessage EmptyMessagePolicy
+	// MaxEntryBytes caps the size of encoded entries. Larger entries are cut
+	// to fit, ending with "...[truncated]" and their line ending, to protect
+	// consumers with line size limits. Truncated entries of structured
+	// encoders, like JSON, are no longer valid. Zero means no limit.
+	MaxEntryBytes int
+	// StackTraceOnlyWithError only logs stack traces for entries at
+	// StackTraceLevel or above if they have a field with StackTraceErrorKey,
+	// e.g. one added with Cause. Stack traces requested with Stack are always
+	// logged.
+	StackTraceOnlyWithError bool
+	// StackTraceErrorKey is the key of the error field, as logged, that
+	// StackTraceOnlyWithError looks for. Defaults to ErrorFieldKey.
+	StackTraceErrorKey string
+	Sampler            Sampler
+	// FallbackEncoder is used to encode entries the Encoder panics on. When
+	// nil, the panic is propagated to the caller.
+	FallbackEncoder Encoder
+	// KeyNormalizer, when set, rewrites field keys before duplicates are
+	// resolved, so keys normalized to the same value are merged. See
+	// SnakeCaseKeys.
+	KeyNormalizer func(string) string
+	// MaxKeyLen limits the length of field keys in bytes, after they are
+	// normalized. Longer keys are cut and suffixed with a hash of the whole
+	// key, e.g. "_1a2b3c4d", so that keys sharing a prefix remain distinct.
+	// Zero means unlimited.
+	MaxKeyLen int
+	// ValueRedactor, when set, rewrites string field values before they are
+	// encoded, e.g. to mask card numbers or emails found in any field. It also
+	// applies to strings in slices and maps, and to the messages of errors
+	// and the output of Stringers, which are replaced with the redacted
+	// strings. It is called for every string value, so it should be fast.
+	ValueRedactor func(string) string
+	// RedactMessages applies ValueRedactor to messages too.
+	RedactMessages bool
+	// PrivatePools gives the logger its own buffer and field pools instead of
+	// the ones shared by all loggers. This isolates high-throughput loggers
+	// from others at the cost of memory: every set of pools keeps its own idle
+	// buffers between garbage collections.
+	PrivatePools bool
+	// ShardedPools gives the logger private pools that keep buffers in free
+	// lists sharded across processors instead of a sync.Pool. It is meant
+	// for loggers called from many goroutines at once, where the pool shows
+	// up in profiles; measure before enabling it.
+	ShardedPools bool
+	// StatsLevel is the level StartStatsReporter logs stats at, from trace to
+	// error. Defaults to info.
+	StatsLevel Level
+	// IncludeHost adds the host name as a "host" field to every entry.
+	IncludeHost bool
+	// IncludePID adds the process ID as a "pid" field to every entry.
+	IncludePID bool
+	// IncludeCaller adds the file and line the entry was logged at as a
+	// "caller" field. Looking up the caller costs about as much as encoding a
+	// few fields. The caller is the first frame of stack traces, set
+	// StackTraceSkip so that it points at the code calling the logger, and
+	// use CallerSkip in logging helpers.
+	IncludeCaller bool
+	// IncludeSequence adds a sequence number to every entry, starting at 1
+	// and incremented by one per logged entry, so consumers can detect lost
+	// entries. Entries are written in the order of their numbers, which
+	// requires encoding them while holding the lock of the output.
+	IncludeSequence bool
+	// IncludeGoroutineID adds the ID of the goroutine that logged the entry as
+	// a "goroutine" field, to tell apart interleaved entries of concurrent
+	// goroutines. The ID is parsed from the header of runtime.Stack, which
+	// costs a few microseconds per entry, many times the cost of encoding it,
+	// so it is meant for debugging.
+	IncludeGoroutineID bool
+	// SequenceKey is the key of the sequence number field. Defaults to "seq".
+	SequenceKey string
+	// GroupContextFields logs the fields added to the context with
+	// ContextWithFields, including the trace ID, as a single object under
+	// ContextFieldsKey instead of alongside the fields of the entry, e.g.
+	// ctx={trace_id=...}. Fields logged with the entry no longer override
+	// context fields with the same keys.
+	GroupContextFields bool
+	// ContextFieldsKey is the key of grouped context fields. Defaults to
+	// "ctx".
+	ContextFieldsKey string
+	// ElapsedKey is the key of the field with the time elapsed since the
+	// start time stored with ContextWithStartTime. Defaults to "elapsed".
+	ElapsedKey string
+	// Name is the name of the logger, logged as a "logger" field. See Named.
+	Name string
+	// Levels overrides Level for loggers by name, see ParseLevels. The entry
+	// with the longest name matching the name of the logger is used.
+	Levels map[string]Level
+	// LevelSchedule sets the level of the logger during windows of time,
+	// overriding Level and SetLevel, e.g. to log debug entries during a
+	// maintenance window or only errors during quiet hours. If windows
+	// overlap, the most verbose level applies. The schedule is evaluated at
+	// most once per second, so windows take effect up to a second late.
+	LevelSchedule []LevelWindow
 }
 
+// EmptyMessagePolicy controls how entries with an empty message are handled.
+type EmptyMessagePolicy int
+
+const (
+	// EmptyMessageAllow logs entries with empty messages as they are.
+	EmptyMessageAllow EmptyMessagePolicy = iota
+	// EmptyMessageWarn logs a warning pointing at the code that logged the
+	// first entry with an empty message, as they are usually mistakes. The
+	// entries themselves are logged as they are.
+	EmptyMessageWarn
+	// EmptyMessageSubstitute replaces empty messages with
+	// EmptyMessagePlaceholder.
+	EmptyMessageSubstitute
+)
+
+// EmptyMessagePlaceholder replaces empty messages with EmptyMessageSubstitute.
+const EmptyMessagePlaceholder = "(no message)"
+
 // Level is the log level type.
 type Level int
 
+// String returns the lowercase name of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "trace"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelPanic:
+		return "panic"
+	case LevelFatal:
+		return "fatal"
+	default:
+		return "Level(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
 // Supported log levels.
 const (
 	LevelTrace Level = iota + 1
@@ -46,10 +236,30 @@ const (
 	LevelFatal
 )
 
+// Time format presets for the TimeFormat encoder options. TimeFormatClock
+// omits the date. The Unix presets format timestamps as the number of seconds,
+// milliseconds, or nanoseconds since the Unix epoch, which the JSON encoder
+// writes as numbers.
+const (
+	TimeFormatISO8601   = "2006-01-02T15:04:05.000Z07:00"
+	TimeFormatClock     = "15:04:05.000"
+	TimeFormatUnix      = "unix"
+	TimeFormatUnixMilli = "unixmilli"
+	TimeFormatUnixNano  = "unixnano"
+)
+
 var (
-	defaultMessageWidth  = 40 // characters
-	defaultTimeFormat    = "2006-01-02 15:04:05.000"
-	defaultTimePrecision = 1 * time.Millisecond
+	defaultMessageWidth        = 40 // characters
+	defaultFieldSeparatorWidth = 2  // characters
+	defaultMaxFrames           = 32
+	defaultNilValue            = "null"
+	defaultErrorKey            = "error"
+	defaultSequenceKey         = "seq"
+	defaultContextFieldsKey    = "ctx"
+	defaultElapsedKey          = "elapsed"
+	defaultTimeFormat          = "2006-01-02 15:04:05.000"
+	defaultTimePrecision       = 1 * time.Millisecond
+	defaultStatsInterval       = time.Minute
 
 	// DurationFieldPrecision controls how duration values are truncated when
 	// logged.
@@ -60,11 +270,15 @@ var (
 	TimeFieldFormat = time.RFC3339
 
 	timeNow = time.Now
+	exit    = os.Exit
 )
 
 // New creates a new Logger instance with the given configuration.
 func New(cfg Config) *Logger {
 	// Set fallback values
+	if lev, ok := levelFor(cfg.Levels, cfg.Name); ok {
+		cfg.Level = lev
+	}
 	if cfg.Level < LevelTrace || cfg.Level > LevelFatal {
 		cfg.Level = LevelInfo
 	}
@@ -74,115 +288,761 @@ func New(cfg Config) *Logger {
 	if cfg.StackTraceLevel < LevelTrace || cfg.StackTraceLevel > LevelFatal {
 		cfg.StackTraceLevel = LevelError
 	}
+	// Stats are logged from a background goroutine, Panic and Fatal levels
+	// would panic or exit
+	if cfg.StatsLevel < LevelTrace || cfg.StatsLevel > LevelError {
+		cfg.StatsLevel = LevelInfo
+	}
+	if cfg.StackTraceMaxFrames <= 0 {
+		cfg.StackTraceMaxFrames = defaultMaxFrames
+	}
+	if cfg.SequenceKey == "" {
+		cfg.SequenceKey = defaultSequenceKey
+	}
+	if cfg.ContextFieldsKey == "" {
+		cfg.ContextFieldsKey = defaultContextFieldsKey
+	}
+	if cfg.ElapsedKey == "" {
+		cfg.ElapsedKey = defaultElapsedKey
+	}
+	if cfg.StackTraceErrorKey == "" {
+		cfg.StackTraceErrorKey = ErrorFieldKey
+	}
 	if cfg.Encoder == nil {
 		cfg.Encoder = NewConsoleEncoder()
 	}
+	if enc, ok := cfg.Encoder.(*ConsoleEncoder); ok && enc.RelativeTime && enc.StartTime.IsZero() {
+		enc.StartTime = timeNow()
+	}
+	// Promoted to types embedding the encoders, which call EncodeStackTrace
+	for _, enc := range []Encoder{cfg.Encoder, cfg.FallbackEncoder} {
+		if enc, ok := enc.(interface{ setStackTraceMaxFrames(int) }); ok {
+			enc.setStackTraceMaxFrames(cfg.StackTraceMaxFrames)
+		}
+	}
+
+	p := sharedPools
+	if cfg.ShardedPools {
+		p = newShardedPools()
+	} else if cfg.PrivatePools {
+		p = newPools()
+	}
+
+	l := &Logger{
+		cfg:      cfg,
+		enc:      cfg.Encoder,
+		fields:   baseFields(cfg),
+		norm:     keyNormalizer(cfg),
+		pools:    p,
+		schedule: newLevelSchedule(cfg.LevelSchedule),
+		sink:     newSink(cfg.Output),
+	}
+	l.level.Store(int64(cfg.Level))
+	return l
+}
+
+// Clone returns a copy of the configuration with its own encoders, so they can
+// be modified without affecting loggers created with the original. Encoders
+// are cloned with their Clone method, which the console and JSON encoders
+// implement:
+//
+//	Clone() Encoder
+//
+// Custom encoders without it are shared by both configurations, as are types
+// that only inherit it by embedding one of the encoders, since the inherited
+// method would return the embedded encoder. The output and the sampler are
+// always shared.
+func (c Config) Clone() Config {
+	c.Encoder = cloneEncoder(c.Encoder)
+	c.FallbackEncoder = cloneEncoder(c.FallbackEncoder)
+	c.Levels = maps.Clone(c.Levels)
+	c.LevelSchedule = slices.Clone(c.LevelSchedule)
+	return c
+}
+
+func cloneEncoder(enc Encoder) Encoder {
+	c, ok := enc.(interface{ Clone() Encoder })
+	if !ok {
+		return enc
+	}
+	// A Clone method promoted from an embedded encoder returns an encoder of
+	// another type
+	if clone := c.Clone(); reflect.TypeOf(clone) == reflect.TypeOf(enc) {
+		return clone
+	}
+	return enc
+}
 
-	return &Logger{
-		cfg: cfg,
-		enc: cfg.Encoder,
+// String returns a summary of the configuration suitable for logging.
+func (c Config) String() string {
+	output := fmt.Sprintf("%T", c.Output)
+	if f, ok := c.Output.(*os.File); ok {
+		output = f.Name()
+	}
+	str := fmt.Sprintf("level=%s output=%s encoder=%T stack_trace_level=%s stack_trace_max_frames=%d",
+		c.Level, output, c.Encoder, c.StackTraceLevel, c.StackTraceMaxFrames)
+	if c.StackTraceOnlyWithError {
+		str += " stack_trace_only_with_error=" + c.StackTraceErrorKey
+	}
+	if c.Sampler != nil {
+		str += fmt.Sprintf(" sampler=%T", c.Sampler)
+	}
+	if c.FallbackEncoder != nil {
+		str += fmt.Sprintf(" fallback_encoder=%T", c.FallbackEncoder)
+	}
+	if c.IncludeHost {
+		str += " include_host=true"
+	}
+	if c.IncludePID {
+		str += " include_pid=true"
+	}
+	if c.IncludeCaller {
+		str += " include_caller=true"
 	}
+	if c.IncludeSequence {
+		str += " include_sequence=true"
+	}
+	if c.IncludeGoroutineID {
+		str += " include_goroutine_id=true"
+	}
+	if c.GroupContextFields {
+		str += " group_context_fields=true"
+	}
+	if c.Name != "" {
+		str += " name=" + c.Name
+	}
+	return str
 }
 
 // DefaultConfig returns a default configuration for the logger.
 func DefaultConfig() Config {
 	return Config{
-		Level:           LevelInfo,
-		Output:          os.Stderr,
-		StackTraceLevel: LevelPanic,
-		StackTraceSkip:  4,
-		Encoder:         NewConsoleEncoder(),
+		Level:               LevelInfo,
+		Output:              os.Stderr,
+		StackTraceLevel:     LevelPanic,
+		StackTraceSkip:      4,
+		PanicOnPanicLevel:   true,
+		StackTraceMaxFrames: defaultMaxFrames,
+		Encoder:             NewConsoleEncoder(),
 	}
 }
 
 // Trace is used to log a message at the Trace level.
 func (l *Logger) Trace(ctx context.Context, msg string, fields ...F) {
-	if l.cfg.Level == LevelTrace {
-		l.print(LevelTrace, msg, makeFields(ctx, fields))
+	if l.enabled(ctx, LevelTrace) {
+		_ = l.print(ctx, LevelTrace, msg, fields)
 	}
 }
 
 // Debug is used to log a message at the Debug level.
 func (l *Logger) Debug(ctx context.Context, msg string, fields ...F) {
-	if l.cfg.Level <= LevelDebug {
-		l.print(LevelDebug, msg, makeFields(ctx, fields))
+	if l.enabled(ctx, LevelDebug) {
+		_ = l.print(ctx, LevelDebug, msg, fields)
 	}
 }
 
 // Info is used to log a message at the Info level.
 func (l *Logger) Info(ctx context.Context, msg string, fields ...F) {
-	if l.cfg.Level <= LevelInfo {
-		l.print(LevelInfo, msg, makeFields(ctx, fields))
+	if l.enabled(ctx, LevelInfo) {
+		_ = l.print(ctx, LevelInfo, msg, fields)
 	}
 }
 
 // Warn is used to log a message at the Warn level.
 func (l *Logger) Warn(ctx context.Context, msg string, fields ...F) {
-	if l.cfg.Level <= LevelWarn {
-		l.print(LevelWarn, msg, makeFields(ctx, fields))
+	if l.enabled(ctx, LevelWarn) {
+		_ = l.print(ctx, LevelWarn, msg, fields)
 	}
 }
 
 // Error is used to log a message at the Error level.
 func (l *Logger) Error(ctx context.Context, msg string, fields ...F) {
-	if l.cfg.Level <= LevelError {
-		l.print(LevelError, msg, makeFields(ctx, fields))
+	if l.enabled(ctx, LevelError) {
+		_ = l.print(ctx, LevelError, msg, fields)
 	}
 }
 
-// Panic is used to log a message at the Panic level.
+// Panic is used to log a message at the Panic level. If PanicOnPanicLevel is
+// enabled, it then panics with the message.
 func (l *Logger) Panic(ctx context.Context, msg string, fields ...F) {
-	if l.cfg.Level <= LevelPanic {
-		l.print(LevelPanic, msg, makeFields(ctx, fields))
+	if l.enabled(ctx, LevelPanic) {
+		_ = l.print(ctx, LevelPanic, msg, fields)
+	}
+	if l.cfg.PanicOnPanicLevel {
+		panic(msg)
 	}
 }
 
 // Fatal is used to log a message at the Fatal level and exit the program.
 func (l *Logger) Fatal(ctx context.Context, msg string, fields ...F) {
-	l.print(LevelFatal, msg, makeFields(ctx, fields))
-	os.Exit(1)
+	_ = l.print(ctx, LevelFatal, msg, fields)
+	exit(1)
+}
+
+// Log is used to log a message at the given level. It behaves exactly like
+// the method named after the level, logging at the Fatal level exits the
+// program and logging at the Panic level panics if PanicOnPanicLevel is
+// enabled. Messages with invalid levels are ignored.
+func (l *Logger) Log(ctx context.Context, lev Level, msg string, fields ...F) {
+	switch {
+	case lev < LevelTrace || lev > LevelFatal:
+		return
+	case lev == LevelFatal:
+		_ = l.print(ctx, LevelFatal, msg, fields)
+		exit(1)
+	case l.enabled(ctx, lev):
+		_ = l.print(ctx, lev, msg, fields)
+	}
+	if lev == LevelPanic && l.cfg.PanicOnPanicLevel {
+		panic(msg)
+	}
+}
+
+// LogAt is like Log, but logs the entry with the given time instead of the
+// current time, e.g. to replay events with their original timestamps.
+func (l *Logger) LogAt(ctx context.Context, t time.Time, lev Level, msg string, fields ...F) {
+	// Copy the field sets so that the marker isn't appended to the slice of
+	// the caller
+	fields = append(fields[:len(fields):len(fields)], F{
+		entryTimeKey:  entryTime(t),
+		callerSkipKey: callerSkip(1),
+	})
+	l.Log(ctx, lev, msg, fields...)
+}
+
+// Sync logs a message at the given level and waits for it to be flushed to
+// durable storage if the output implements a Sync method, like *os.File does.
+// Unlike other logging methods, the entry is written regardless of the
+// configured level and sampler. Logging at the Fatal level exits the program
+// once the entry is synced, logging at the Panic level panics if
+// PanicOnPanicLevel is enabled. If the context is done before the sync
+// completes, its error is returned.
+func (l *Logger) Sync(ctx context.Context, lev Level, msg string, fields ...F) error {
+	if lev < LevelTrace || lev > LevelFatal {
+		return fmt.Errorf("invalid log level: %d", lev)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := l.print(ctx, lev, msg, fields)
+	if err == nil {
+		err = l.sync(ctx)
+	}
+	if lev == LevelFatal {
+		exit(1)
+	}
+	if lev == LevelPanic && l.cfg.PanicOnPanicLevel {
+		panic(msg)
+	}
+	return err
+}
+
+// Config returns a copy of the effective configuration, with the defaults
+// applied by New. The copy is shallow: the output, encoder, and sampler are
+// shared with the logger and should not be modified. Use Config.Clone to get
+// encoders that can be modified.
+func (l *Logger) Config() Config {
+	cfg := l.cfg
+	l.lock.Lock()
+	cfg.Output = l.out
+	l.lock.Unlock()
+	cfg.Level = Level(l.level.Load())
+	return cfg
+}
+
+// SetLevel changes the minimum logging level. It is safe to call concurrently
+// with logging. Invalid levels are ignored.
+func (l *Logger) SetLevel(lev Level) {
+	if lev >= LevelTrace && lev <= LevelFatal {
+		l.level.Store(int64(lev))
+	}
+}
+
+// Scoped temporarily sets the minimum logging level and returns a function
+// that restores the previous level, e.g.
+//
+//	defer logger.Scoped(blip.LevelDebug)()
+//
+// The level is changed for the whole logger, not just the calling goroutine.
+// Overlapping scopes must be restored in reverse order, so Scoped is meant
+// for debugging a single goroutine. Use ContextWithLevel to change the level
+// for a single request.
+func (l *Logger) Scoped(lev Level) (restore func()) {
+	prev := Level(l.level.Load())
+	l.SetLevel(lev)
+	return func() { l.SetLevel(prev) }
+}
+
+// Counts returns the number of entries logged at each level since the logger
+// was created. Entries dropped due to the level or sampler are not counted.
+func (l *Logger) Counts() map[Level]uint64 {
+	counts := make(map[Level]uint64, LevelFatal)
+	for lev := LevelTrace; lev <= LevelFatal; lev++ {
+		counts[lev] = l.counts[lev].Load()
+	}
+	return counts
+}
+
+// PoolStats returns the number of buffers allocated and reused by the logger.
+// Loggers without private pools share them, and so share the stats.
+func (l *Logger) PoolStats() PoolStats {
+	return l.pools.stats()
+}
+
+// SetOutput replaces the output the logger writes to. It is safe to call
+// concurrently with logging. The previous output is not flushed or closed,
+// that is the responsibility of the caller. A nil output disables logging,
+// like Discard. Loggers sharing the output through Named switch too.
+func (l *Logger) SetOutput(w io.Writer) {
+	if w == nil {
+		w = Discard
+	}
+	l.lock.Lock()
+	l.out = w
+	l.updateDiscard()
+	l.lock.Unlock()
+}
+
+// Close stops the logger from accepting entries and flushes, syncs, and closes
+// its output, depending on the methods it implements, like CloseOnSignal does.
+// The standard output and error streams are flushed but never closed.
+//
+// Entries whose logging calls returned before Close was called are written
+// before the output is flushed. Entries logged concurrently with Close are
+// either written before the flush or dropped, never written after it. Entries
+// logged after Close are dropped, including those passed to WriteRaw, and the
+// logging methods returning errors return ErrClosed; see Dropped. Fatal and
+// Panic levels still exit and panic. Loggers sharing the output through Named
+// are closed too. Closing a closed logger does nothing.
+func (l *Logger) Close() error {
+	l.lock.Lock()
+	if l.closed.Load() {
+		l.lock.Unlock()
+		return nil
+	}
+	l.closed.Store(true)
+	out := l.out
+	l.lock.Unlock()
+
+	return l.flushOutput(out != os.Stdout && out != os.Stderr)
+}
+
+// Dropped returns the number of entries dropped because they were logged
+// after the logger was closed.
+func (l *Logger) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// Discard is an output that disables logging. Unlike with io.Discard, entries
+// are dropped before they are encoded, so logging costs next to nothing. The
+// Fatal and Panic levels still exit and panic. While the logger has
+// subscribers, see Subscribe, entries are encoded and passed to them.
+var Discard io.Writer = discard{}
+
+type discard struct{}
+
+func (discard) Write(b []byte) (int, error) { return len(b), nil }
+
+// LevelWriter is implemented by outputs that handle entries according to their
+// level, e.g. by reporting them with a matching severity. Loggers call
+// WriteLevel instead of Write for every logged entry. Entries written with
+// WriteRaw have no level and are written with Write.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(lev Level, b []byte) (int, error)
 }
 
 //
 // Printing
 //
 
-func (l *Logger) print(lev Level, msg string, fields *[]Field) {
-	buf := getBuffer()
-	defer putBuffer(buf)
+func (l *Logger) enabled(ctx context.Context, lev Level) bool {
+	if l.discard.Load() {
+		return false
+	}
+	if l.minLevel() > lev && !levelOverridden(ctx, lev) {
+		return false
+	}
+	return l.cfg.Sampler == nil || l.cfg.Sampler.Sample(lev)
+}
+
+// minLevel returns the minimum level of entries to log, according to the
+// level schedule if it has an active window.
+func (l *Logger) minLevel() Level {
+	if l.schedule != nil {
+		if lev := l.schedule.level(timeNow()); lev != 0 {
+			return lev
+		}
+	}
+	return Level(l.level.Load())
+}
+
+func (l *Logger) print(ctx context.Context, lev Level, msg string, ff []F) error {
+	if l.closed.Load() {
+		l.dropped.Add(1)
+		return ErrClosed
+	}
+	// Capture the time of the call before doing any work, it is shared by all
+	// encoders of the entry
+	now := timeNow()
+	l.counts[lev].Add(1)
+	buf := l.pools.getBuffer()
+	defer l.pools.putBuffer(buf)
+	var group string
+	if l.cfg.GroupContextFields {
+		group = l.cfg.ContextFieldsKey
+	}
+	fields, m := makeFields(ctx, lev, l.pools, l.norm, l.cfg.StackTraceMaxFrames, group, l.fields, ff)
+	if !m.at.IsZero() {
+		now = m.at
+	}
+	if msg == "" && l.cfg.OnEmptyMessage != EmptyMessageAllow {
+		msg = l.emptyMessage(ctx, l.cfg.StackTraceSkip+m.skip)
+	}
+	if l.cfg.IncludeSequence {
+		if fields == nil {
+			fields = l.pools.getFields()
+		}
+		// Assigned by write, in the order entries are written
+		addField(fields, nil, l.cfg.SequenceKey, uint64(0))
+	}
+	if l.cfg.IncludeCaller {
+		if fields == nil {
+			fields = l.pools.getFields()
+		}
+		addField(fields, nil, callerKey, caller(l.cfg.StackTraceSkip+m.skip))
+	}
+	if l.cfg.IncludeGoroutineID {
+		if fields == nil {
+			fields = l.pools.getFields()
+		}
+		addField(fields, nil, goroutineKey, goroutineID())
+	}
+	if start := StartTimeFromContext(ctx); !start.IsZero() {
+		if fields == nil {
+			fields = l.pools.getFields()
+		}
+		addField(fields, nil, l.cfg.ElapsedKey, now.Sub(start).Truncate(DurationFieldPrecision))
+	}
+	if l.cfg.ValueRedactor != nil {
+		redactFields(fields, l.cfg.ValueRedactor)
+		if l.cfg.RedactMessages {
+			msg = l.cfg.ValueRedactor(msg)
+		}
+	}
+	e := Entry{
+		Time:    now,
+		Level:   lev,
+		Name:    l.cfg.Name,
+		Message: msg,
+		Fields:  fields,
+	}
+	if m.stack || l.wantStackTrace(lev, fields) {
+		pc := callers(l.cfg.StackTraceSkip+m.skip, l.cfg.StackTraceMaxFrames)
+		e.StackTrace = formatFrames(pc, l.cfg.StackTraceMaxFrames)
+		if len(pc) > 0 {
+			e.stackPC = pc[0]
+		}
+	}
 
-	l.enc.Start(buf)
-	l.enc.EncodeTime(buf)
-	l.enc.EncodeLevel(buf, lev)
-	l.enc.EncodeMessage(buf, msg)
-	l.enc.EncodeFields(buf, lev, fields)
-	if fields != nil {
-		putFields(fields)
+	err := l.write(buf, e)
+	l.pools.putFields(fields)
+	return err
+}
+
+// emptyMessage handles an empty message according to the configured policy
+// and returns the message to log. The skip is that of the caller of print.
+func (l *Logger) emptyMessage(ctx context.Context, skip int) string {
+	switch l.cfg.OnEmptyMessage {
+	case EmptyMessageSubstitute:
+		return EmptyMessagePlaceholder
+	case EmptyMessageWarn:
+		if l.warnedEmpty.CompareAndSwap(false, true) && l.enabled(ctx, LevelWarn) {
+			// One frame deeper than print
+			_ = l.print(ctx, LevelWarn, "Logged an entry with an empty message at "+caller(skip+1), nil)
+		}
 	}
-	if lev >= l.cfg.StackTraceLevel {
-		l.enc.EncodeStackTrace(buf, l.cfg.StackTraceSkip)
+	return ""
+}
+
+// write encodes the entry into the buffer and writes it to the output.
+func (l *Logger) write(buf *Buffer, e Entry) error {
+	if l.cfg.IncludeSequence {
+		// Sequence numbers are assigned and entries encoded under the lock, so
+		// that entries are written in the order of their numbers
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if !l.closed.Load() {
+			l.setSequence(e.Fields)
+			l.encode(buf, e)
+		}
+	} else {
+		l.encode(buf, e)
+		l.lock.Lock()
+		defer l.lock.Unlock()
+	}
+
+	if l.closed.Load() {
+		// Closed while the entry was encoded, it is not counted after all
+		l.counts[e.Level].Add(^uint64(0))
+		l.dropped.Add(1)
+		return ErrClosed
+	}
+	var err error
+	if lw, ok := l.out.(LevelWriter); ok {
+		_, err = lw.WriteLevel(e.Level, buf.b)
+	} else {
+		_, err = l.out.Write(buf.b)
+	}
+	l.publish(buf.b)
+	return err
+}
+
+// encode encodes the entry with the encoder of the logger, or with the
+// fallback encoder if it panics.
+func (l *Logger) encode(buf *Buffer, e Entry) {
+	if l.cfg.FallbackEncoder == nil {
+		Encode(l.enc, buf, e)
+	} else if !tryEncode(l.enc, buf, e) {
+		buf.b = buf.b[:0]
+		Encode(l.cfg.FallbackEncoder, buf, e)
+	}
+	if l.cfg.MaxEntryBytes > 0 && len(buf.b) > l.cfg.MaxEntryBytes {
+		truncateEntry(buf, l.cfg.MaxEntryBytes)
+	}
+}
+
+// setSequence sets the sequence number field of an entry to the next number.
+func (l *Logger) setSequence(fields *[]Field) {
+	if fields == nil {
+		return
+	}
+	for i := range *fields {
+		if (*fields)[i].Key == l.cfg.SequenceKey {
+			(*fields)[i].Value = l.seq.Add(1)
+			return
+		}
+	}
+}
+
+const truncatedMarker = "...[truncated]"
+
+// truncateEntry cuts an encoded entry to at most n bytes, including the marker
+// and the line ending of the entry. The entry is cut at a rune boundary.
+func truncateEntry(buf *Buffer, n int) {
+	var le string
+	switch {
+	case bytes.HasSuffix(buf.b, []byte("\r\n")):
+		le = "\r\n"
+	case bytes.HasSuffix(buf.b, []byte("\n")):
+		le = "\n"
+	}
+	cut := max(n-len(truncatedMarker)-len(le), 0)
+	for cut > 0 && !utf8.RuneStart(buf.b[cut]) {
+		cut--
+	}
+	buf.b = append(append(buf.b[:cut], truncatedMarker...), le...)
+}
+
+// WriteRaw writes an already encoded entry to the output, e.g. a line
+// forwarded from a child process, appending a newline if it doesn't end with
+// one. The entry is written and passed to subscribers like encoded entries
+// are, without being encoded, filtered, or counted. Nothing is written if the
+// output is Discard and there are no subscribers.
+func (l *Logger) WriteRaw(b []byte) error {
+	if l.discard.Load() {
+		return nil
+	}
+	buf := l.pools.getBuffer()
+	defer l.pools.putBuffer(buf)
+	buf.Write(b)
+	if !bytes.HasSuffix(b, []byte{'\n'}) {
+		buf.WriteBytes('\n')
 	}
-	l.enc.End(buf)
 
 	l.lock.Lock()
-	_, _ = l.cfg.Output.Write(buf.b)
+	defer l.lock.Unlock()
+	if l.closed.Load() {
+		l.dropped.Add(1)
+		return ErrClosed
+	}
+	_, err := l.out.Write(buf.b)
+	l.publish(buf.b)
+	return err
+}
+
+// wantStackTrace reports whether an entry needs a stack trace according to
+// the configured level and error key.
+func (l *Logger) wantStackTrace(lev Level, fields *[]Field) bool {
+	if lev < l.cfg.StackTraceLevel {
+		return false
+	}
+	if !l.cfg.StackTraceOnlyWithError {
+		return true
+	}
+	if fields == nil {
+		return false
+	}
+	for _, f := range *fields {
+		if f.Key == l.cfg.StackTraceErrorKey {
+			return true
+		}
+	}
+	return false
+}
+
+// sync flushes the output to durable storage if it supports that. It returns
+// early if the context is done.
+func (l *Logger) sync(ctx context.Context) error {
+	l.lock.Lock()
+	s, ok := l.out.(interface{ Sync() error })
 	l.lock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Sync() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tryEncode encodes an entry and reports whether the encoder completed without
+// panicking.
+func tryEncode(enc Encoder, buf *Buffer, e Entry) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	Encode(enc, buf, e)
+	return true
 }
 
 //
 // Helpers
 //
 
-func stackTrace(skip int) string {
-	// Get up to 100 stack frames
-	pc := make([]uintptr, 100)
-	// +2 frames to skip for runtime.Callers and stackTrace itself
-	n := runtime.Callers(skip+2, pc)
-	frames := runtime.CallersFrames(pc[:n])
+// keyNormalizer returns the configured key normalizer combined with the key
+// length limit.
+func keyNormalizer(cfg Config) func(string) string {
+	norm, n := cfg.KeyNormalizer, cfg.MaxKeyLen
+	switch {
+	case n <= 0:
+		return norm
+	case norm == nil:
+		return func(key string) string { return truncateKey(key, n) }
+	default:
+		return func(key string) string { return truncateKey(norm(key), n) }
+	}
+}
+
+// baseFields returns the fields added to every entry. They are resolved once to
+// avoid system calls when logging.
+func baseFields(cfg Config) F {
+	if !cfg.IncludeHost && !cfg.IncludePID && cfg.Name == "" {
+		return nil
+	}
+
+	f := F{}
+	if cfg.Name != "" {
+		f["logger"] = cfg.Name
+	}
+	if cfg.IncludeHost {
+		if host, err := os.Hostname(); err == nil {
+			f["host"] = host
+		}
+	}
+	if cfg.IncludePID {
+		f["pid"] = os.Getpid()
+	}
+	return f
+}
+
+const callerKey = "caller"
+
+// caller returns the file and line of the frame stack traces start at, e.g.
+// "blip/logger.go:42". The number of frames to skip includes caller itself.
+func caller(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "???"
+	}
+	// Keep the directory of the file, it is usually the package name
+	if i := strings.LastIndexByte(file, '/'); i > 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			file = file[j+1:]
+		}
+	}
+	return file + ":" + strconv.Itoa(line)
+}
+
+const goroutineKey = "goroutine"
+
+// goroutineID returns the ID of the current goroutine, parsed from the header
+// of its stack trace, e.g. "goroutine 42 [running]:". It returns 0 if the
+// header can't be parsed.
+func goroutineID() uint64 {
+	var b [64]byte
+	s := b[:runtime.Stack(b[:], false)]
+	s = bytes.TrimPrefix(s, []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	id, err := strconv.ParseUint(string(s), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// StackTrace returns the stack trace of the calling goroutine formatted the
+// way loggers format it, for encoders implementing EncodeStackTrace. Skip is
+// the number of frames to skip, 0 starting the stack trace at the caller of
+// StackTrace.
+func StackTrace(skip int) string {
+	return stackTrace(skip+2, defaultMaxFrames)
+}
+
+// maxFrames returns n, or the default number of stack frames if n is zero.
+func maxFrames(n int) int {
+	if n <= 0 {
+		return defaultMaxFrames
+	}
+	return n
+}
+
+// stackTrace formats up to maxFrames stack frames. The number of frames to
+// skip includes stackTrace itself.
+func stackTrace(skip, maxFrames int) string {
+	return formatFrames(callers(skip+1, maxFrames), maxFrames)
+}
+
+// callers returns the program counters of up to maxFrames stack frames and
+// one extra frame to tell whether the stack trace is truncated. The number of
+// frames to skip includes callers itself.
+func callers(skip, maxFrames int) []uintptr {
+	pc := make([]uintptr, maxFrames+1)
+	// +1 frame to skip for runtime.Callers
+	n := runtime.Callers(skip+1, pc)
+	return pc[:n]
+}
 
+// formatFrames formats up to maxFrames frames of the given program counters
+// as returned by runtime.Callers.
+func formatFrames(pc []uintptr, maxFrames int) string {
+	frames := runtime.CallersFrames(pc)
 	var buf bytes.Buffer
-	for {
+	for i := 0; ; i++ {
 		f, more := frames.Next()
+		if i == maxFrames {
+			buf.WriteString("... (truncated)\n")
+			break
+		}
 		buf.WriteString(fmt.Sprintf("%s\n\t%s:%d\n", f.Function, f.File, f.Line))
 		if !more {
 			break
@@ -191,17 +1051,39 @@ func stackTrace(skip int) string {
 	return buf.String()
 }
 
-func timeCache(format string, precision time.Duration) func(time.Time) string {
-	var lastTime time.Time
-	var lastTimeStr string
+// timeCache caches the formatted representation of the most recent timestamp.
+// It is safe for concurrent use and its zero value is ready to use.
+type timeCache struct {
+	last atomic.Value // *cachedTime
+}
 
-	return func(t time.Time) string {
-		if !lastTime.IsZero() && t.Sub(lastTime) < precision {
-			return lastTimeStr
-		}
+type cachedTime struct {
+	t   time.Time
+	str string
+}
 
-		lastTime = t
-		lastTimeStr = t.Format(format)
-		return lastTimeStr
+func (c *timeCache) format(t time.Time, layout string, precision time.Duration) string {
+	if last, ok := c.last.Load().(*cachedTime); ok && t.Sub(last.t) < precision && t.Location() == last.t.Location() {
+		return last.str
 	}
+
+	buf := Buffer{make([]byte, 0, 64)}
+	buf.WriteTime(t, layout)
+	str := string(buf.b)
+	c.last.Store(&cachedTime{t, str})
+	return str
+}
+
+// inLocation returns the time in the given location, or unchanged if the
+// location is nil.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	if loc != nil {
+		t = t.In(loc)
+	}
+	return t
+}
+
+// isNumericTimeFormat reports whether the layout formats time as a number.
+func isNumericTimeFormat(layout string) bool {
+	return layout == TimeFormatUnix || layout == TimeFormatUnixMilli || layout == TimeFormatUnixNano
 }
@@ -0,0 +1,93 @@
+package blip
+
+import "context"
+
+// CtxLogger is a logger handle bound to a context. It saves passing the
+// context to every call in code that logs a lot within the same scope.
+// It is a small value and is cheap to create.
+type CtxLogger struct {
+	l   *Logger
+	ctx context.Context //nolint:containedctx // Binding a context is the point
+}
+
+// Ctx returns a handle that logs with the given context.
+func (l *Logger) Ctx(ctx context.Context) CtxLogger {
+	return CtxLogger{l: l, ctx: ctx}
+}
+
+// Cached returns a handle that looks up the fields, error fields, the level
+// override, and the start time of its context once, instead of walking the
+// context chain on every call. This pays off when logging many entries with a
+// deep context, e.g. in a request handler. Contexts are immutable, so the
+// cached values only go stale if the field set stored in the context is
+// modified in place.
+func (c CtxLogger) Cached() CtxLogger {
+	c.ctx = &cachedContext{
+		Context: c.ctx,
+		fields:  FieldsFromContext(c.ctx),
+		level:   c.ctx.Value(levelContextKey{}),
+		start:   c.ctx.Value(startTimeContextKey{}),
+		errors:  ErrorFieldsFromContext(c.ctx),
+	}
+	return c
+}
+
+// cachedContext answers lookups of logging fields, error fields, level
+// overrides, and start times without consulting its parent.
+type cachedContext struct {
+	context.Context
+	fields F
+	level  any
+	start  any
+	errors F
+}
+
+func (c *cachedContext) Value(key any) any {
+	switch key {
+	case contextKey{}:
+		return c.fields
+	case levelContextKey{}:
+		return c.level
+	case startTimeContextKey{}:
+		return c.start
+	case errorFieldsContextKey{}:
+		return c.errors
+	default:
+		return c.Context.Value(key)
+	}
+}
+
+// Trace is used to log a message at the Trace level.
+func (c CtxLogger) Trace(msg string, fields ...F) {
+	c.l.Trace(c.ctx, msg, fields...)
+}
+
+// Debug is used to log a message at the Debug level.
+func (c CtxLogger) Debug(msg string, fields ...F) {
+	c.l.Debug(c.ctx, msg, fields...)
+}
+
+// Info is used to log a message at the Info level.
+func (c CtxLogger) Info(msg string, fields ...F) {
+	c.l.Info(c.ctx, msg, fields...)
+}
+
+// Warn is used to log a message at the Warn level.
+func (c CtxLogger) Warn(msg string, fields ...F) {
+	c.l.Warn(c.ctx, msg, fields...)
+}
+
+// Error is used to log a message at the Error level.
+func (c CtxLogger) Error(msg string, fields ...F) {
+	c.l.Error(c.ctx, msg, fields...)
+}
+
+// Panic is used to log a message at the Panic level.
+func (c CtxLogger) Panic(msg string, fields ...F) {
+	c.l.Panic(c.ctx, msg, fields...)
+}
+
+// Fatal is used to log a message at the Fatal level and exit the program.
+func (c CtxLogger) Fatal(msg string, fields ...F) {
+	c.l.Fatal(c.ctx, msg, fields...)
+}
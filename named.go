@@ -0,0 +1,86 @@
+package blip
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Named creates a logger with the same configuration and the given name
+// appended to the name of the logger, separated with a dot. The name is logged
+// as a "logger" field. The new logger has the current level of the logger,
+// unless Config.Levels has an entry matching the name.
+//
+// The new logger shares the output with the logger: entries of both are
+// written one at a time and numbered by the same sequence, subscribers and
+// SetOutput apply to both, and closing either closes both.
+func (l *Logger) Named(name string) *Logger {
+	cfg := l.Config()
+	if cfg.Name != "" {
+		name = cfg.Name + "." + name
+	}
+	cfg.Name = name
+	child := New(cfg)
+	child.sink = l.sink
+	return child
+}
+
+// ParseLevel parses a level name, e.g. "debug", or its four letter label
+// written by the console encoder, e.g. "DEBU", so tools can parse the levels of
+// logged entries. The name is case-insensitive and surrounding spaces are
+// ignored.
+func ParseLevel(s string) (Level, error) {
+	name := strings.TrimSpace(s)
+	for lev := LevelTrace; lev <= LevelFatal; lev++ {
+		// Console labels are the first four letters of the names
+		if full := lev.String(); strings.EqualFold(name, full) || strings.EqualFold(name, full[:4]) {
+			return lev, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level: %q", s)
+}
+
+// ParseLevels parses a per-name level spec, e.g. "db=debug,http=warn", into a
+// map that can be used as Config.Levels. A level without a name applies to
+// all loggers not matched by other entries. It is meant to read levels from
+// the environment:
+//
+//	cfg.Levels, err = blip.ParseLevels(os.Getenv("BLIP_LEVELS"))
+func ParseLevels(spec string) (map[string]Level, error) {
+	levels := map[string]Level{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, levName, ok := strings.Cut(entry, "=")
+		if !ok {
+			name, levName = "", entry
+		}
+		lev, err := ParseLevel(strings.TrimSpace(levName))
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(name)] = lev
+	}
+	return levels, nil
+}
+
+// levelFor returns the level configured for the name by its longest matching
+// entry. Names match whole dot-separated segments, e.g. "db" matches "db" and
+// "db.pool" but not "dbx".
+func levelFor(levels map[string]Level, name string) (Level, bool) {
+	for {
+		if lev, ok := levels[name]; ok {
+			return lev, true
+		}
+		if name == "" {
+			return 0, false
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			name = ""
+		} else {
+			name = name[:i]
+		}
+	}
+}
@@ -5,6 +5,9 @@ package log
 
 import (
 	"context"
+	"io"
+	"os"
+	"time"
 
 	"github.com/localhots/blip"
 )
@@ -19,6 +22,29 @@ func Setup(cfg blip.Config) {
 	logger = blip.New(cfg)
 }
 
+// SetOutput replaces the output the logger writes to. The previous output is
+// not flushed or closed.
+func SetOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
+// SetLevel changes the minimum logging level.
+func SetLevel(lev blip.Level) {
+	logger.SetLevel(lev)
+}
+
+// FlushOnSignal flushes the output of the logger when the process receives one
+// of the given signals, SIGINT and SIGTERM by default. Call it after Setup.
+func FlushOnSignal(sigs ...os.Signal) (cancel func()) {
+	return blip.FlushOnSignal(logger, sigs...)
+}
+
+// Close stops the logger from accepting entries and flushes and closes its
+// output. Entries logged after Close are dropped.
+func Close() error {
+	return logger.Close()
+}
+
 // Trace is used to log a message at the Trace level.
 func Trace(msg string, fields ...F) {
 	logger.Trace(context.Background(), msg, fields...)
@@ -44,7 +70,8 @@ func Error(msg string, fields ...F) {
 	logger.Error(context.Background(), msg, fields...)
 }
 
-// Panic is used to log a message at the Panic level.
+// Panic is used to log a message at the Panic level and panic, unless
+// PanicOnPanicLevel is disabled.
 func Panic(msg string, fields ...F) {
 	logger.Panic(context.Background(), msg, fields...)
 }
@@ -54,9 +81,52 @@ func Fatal(msg string, fields ...F) {
 	logger.Fatal(context.Background(), msg, fields...)
 }
 
+// Log is used to log a message at the given level.
+func Log(lev blip.Level, msg string, fields ...F) {
+	logger.Log(context.Background(), lev, msg, fields...)
+}
+
+// LogAt is used to log a message at the given level with the given time.
+func LogAt(t time.Time, lev blip.Level, msg string, fields ...F) {
+	logger.LogAt(context.Background(), t, lev, msg, fields...)
+}
+
 // Cause returns a field set that wraps the given error in a standardized way.
+// The stack trace carried by the error, if any, is included.
 func Cause(err error) F {
-	return F{"error": err.Error()}
+	return blip.Cause(err)
+}
+
+// CauseWithKey is like Cause, but logs the error under the given key.
+func CauseWithKey(key string, err error) F {
+	return blip.CauseWithKey(key, err)
+}
+
+// Struct returns a field set with the exported fields of a struct.
+func Struct(v any) F {
+	return blip.Struct(v)
+}
+
+// Diff returns a field set describing the keys whose values differ between
+// the field sets before and after a change.
+func Diff(before, after F) F {
+	return blip.Diff(before, after)
+}
+
+// CallerSkip returns a field set that skips additional stack frames when
+// looking up the caller and the stack trace, for use in logging helpers.
+func CallerSkip(n int) F {
+	return blip.CallerSkip(n)
+}
+
+// Stack returns a field set that forces a stack trace to be logged.
+func Stack() F {
+	return blip.Stack()
+}
+
+// Every creates a gate that allows logging at most once per interval.
+func Every(interval time.Duration) *blip.Gate {
+	return blip.Every(interval)
 }
 
 // ContextWithFields adds logging fields to the context.
@@ -64,7 +134,22 @@ func ContextWithFields(ctx context.Context, fields F) context.Context {
 	return blip.ContextWithFields(ctx, fields)
 }
 
+// MergeContextFields adds the logging fields of src to the fields of dst.
+func MergeContextFields(dst, src context.Context) context.Context {
+	return blip.MergeContextFields(dst, src)
+}
+
 // FieldsFromContext retrieves the field set from the context.
 func FieldsFromContext(ctx context.Context) F {
 	return blip.FieldsFromContext(ctx)
 }
+
+// ContextWithTraceID adds a trace ID to the context unless it already has one.
+func ContextWithTraceID(ctx context.Context) context.Context {
+	return blip.ContextWithTraceID(ctx)
+}
+
+// TraceIDFromContext retrieves the trace ID from the context.
+func TraceIDFromContext(ctx context.Context) string {
+	return blip.TraceIDFromContext(ctx)
+}
@@ -0,0 +1,81 @@
+package blip
+
+import (
+	"context"
+	"sync"
+)
+
+// maxOnceMessages is the number of messages remembered by the *Once methods.
+// Once the limit is reached, the oldest messages are forgotten and can be
+// logged again.
+const maxOnceMessages = 1024
+
+// TraceOnce is like Trace, but logs a message at most once.
+func (l *Logger) TraceOnce(ctx context.Context, msg string, fields ...F) {
+	if l.enabled(ctx, LevelTrace) && l.once.add(msg) {
+		_ = l.print(ctx, LevelTrace, msg, fields)
+	}
+}
+
+// DebugOnce is like Debug, but logs a message at most once.
+func (l *Logger) DebugOnce(ctx context.Context, msg string, fields ...F) {
+	if l.enabled(ctx, LevelDebug) && l.once.add(msg) {
+		_ = l.print(ctx, LevelDebug, msg, fields)
+	}
+}
+
+// InfoOnce is like Info, but logs a message at most once.
+func (l *Logger) InfoOnce(ctx context.Context, msg string, fields ...F) {
+	if l.enabled(ctx, LevelInfo) && l.once.add(msg) {
+		_ = l.print(ctx, LevelInfo, msg, fields)
+	}
+}
+
+// WarnOnce is like Warn, but logs a message at most once. It is useful for
+// deprecation warnings and other messages that would otherwise be logged
+// repeatedly. Messages are deduplicated by their text only, fields are not
+// taken into account. The logger remembers up to 1024 messages, once the
+// limit is reached the oldest messages are forgotten.
+func (l *Logger) WarnOnce(ctx context.Context, msg string, fields ...F) {
+	if l.enabled(ctx, LevelWarn) && l.once.add(msg) {
+		_ = l.print(ctx, LevelWarn, msg, fields)
+	}
+}
+
+// ErrorOnce is like Error, but logs a message at most once.
+func (l *Logger) ErrorOnce(ctx context.Context, msg string, fields ...F) {
+	if l.enabled(ctx, LevelError) && l.once.add(msg) {
+		_ = l.print(ctx, LevelError, msg, fields)
+	}
+}
+
+// onceSet is a bounded set of messages. When full, the oldest message is
+// evicted. Its zero value is ready to use.
+type onceSet struct {
+	lock  sync.Mutex
+	seen  map[string]struct{}
+	order []string // Ring buffer of messages in insertion order
+	next  int
+}
+
+// add adds a message to the set and reports whether it was not there before.
+func (s *onceSet) add(msg string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.seen[msg]; ok {
+		return false
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]struct{})
+	}
+	if len(s.order) < maxOnceMessages {
+		s.order = append(s.order, msg)
+	} else {
+		delete(s.seen, s.order[s.next])
+		s.order[s.next] = msg
+		s.next = (s.next + 1) % maxOnceMessages
+	}
+	s.seen[msg] = struct{}{}
+	return true
+}
@@ -0,0 +1,69 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWarnOnce(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Output = &buf
+	cfg.Encoder = &ConsoleEncoder{}
+	logger := New(cfg)
+	ctx := context.Background()
+
+	for i := range 10 {
+		logger.WarnOnce(ctx, "Deprecated option", F{"i": i})
+	}
+	logger.WarnOnce(ctx, "Another deprecated option")
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("expected 2 entries, got %d:\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "i=0") {
+		t.Errorf("expected the first call to be logged, got:\n%s", buf.String())
+	}
+}
+
+func TestOnceDisabledLevel(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Output = &buf
+	cfg.Level = LevelInfo
+	logger := New(cfg)
+	ctx := context.Background()
+
+	// Messages dropped due to the level are not remembered
+	logger.DebugOnce(ctx, "Message")
+	logger.InfoOnce(ctx, "Message")
+	if !strings.Contains(buf.String(), "Message") {
+		t.Errorf("expected the message to be logged, got %q", buf.String())
+	}
+}
+
+func TestOnceSetEviction(t *testing.T) {
+	var s onceSet
+	for i := range maxOnceMessages {
+		if !s.add(fmt.Sprint(i)) {
+			t.Fatalf("expected %d to be added", i)
+		}
+	}
+	if s.add("0") {
+		t.Error("expected 0 to be remembered")
+	}
+
+	// Evicts the oldest message
+	s.add("new")
+	if len(s.seen) != maxOnceMessages {
+		t.Errorf("expected %d messages, got %d", maxOnceMessages, len(s.seen))
+	}
+	if !s.add("0") {
+		t.Error("expected 0 to be evicted")
+	}
+	if s.add("2") {
+		t.Error("expected 2 to be remembered")
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/localhots/blip/pkgerrors
+
+go 1.23.4
+
+require (
+	github.com/localhots/blip v0.0.0
+	github.com/pkg/errors v0.9.1
+)
+
+replace github.com/localhots/blip => ../
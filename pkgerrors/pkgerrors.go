@@ -0,0 +1,59 @@
+// Package pkgerrors logs the stack traces of errors created with
+// github.com/pkg/errors. It is kept separate so that the blip package does not
+// depend on it.
+//
+//	log.Error(ctx, "Task failed", pkgerrors.Cause(err))
+package pkgerrors
+
+import (
+	"errors"
+
+	"github.com/localhots/blip"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Cause is like blip.Cause, but also logs the stack trace of the innermost
+// error in the chain created with github.com/pkg/errors under the
+// "error_stack" key.
+func Cause(err error) blip.F {
+	return blip.Cause(withStack(err))
+}
+
+// CauseWithKey is like Cause, but logs the error under the given key and its
+// stack trace under the key with a "_stack" suffix.
+func CauseWithKey(key string, err error) blip.F {
+	return blip.CauseWithKey(key, withStack(err))
+}
+
+type stackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// stackError exposes the stack trace of a github.com/pkg/errors error the way
+// blip reads it. Its message is the message of the wrapped error.
+type stackError struct {
+	error
+	pc []uintptr
+}
+
+func (e stackError) StackTrace() []uintptr { return e.pc }
+func (e stackError) Unwrap() error         { return e.error }
+
+// withStack wraps the error with the stack trace of the innermost error in the
+// chain that has one, which is the closest to where the error occurred.
+func withStack(err error) error {
+	var st pkgerrors.StackTrace
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if s, ok := e.(stackTracer); ok {
+			st = s.StackTrace()
+		}
+	}
+	if len(st) == 0 {
+		return err
+	}
+	pc := make([]uintptr, len(st))
+	for i, f := range st {
+		pc[i] = uintptr(f)
+	}
+	return stackError{error: err, pc: pc}
+}
@@ -0,0 +1,79 @@
+package pkgerrors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/localhots/blip"
+	pkgerrors "github.com/pkg/errors"
+)
+
+func newLogger(buf *bytes.Buffer) *blip.Logger {
+	enc := blip.NewJSONEncoder()
+	enc.TimeFormat = ""
+	return blip.New(blip.Config{Output: buf, Encoder: enc, StackTraceLevel: blip.LevelPanic})
+}
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode %q: %v", buf.String(), err)
+	}
+	return entry
+}
+
+func newError() error {
+	return pkgerrors.New("task failed")
+}
+
+func TestCause(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf)
+	err := pkgerrors.Wrap(newError(), "running task")
+	err = fmt.Errorf("handling request: %w", err)
+	logger.Error(context.Background(), "Task failed", Cause(err))
+
+	entry := decode(t, &buf)
+	if entry["error"] != "handling request: running task: task failed" {
+		t.Errorf("unexpected error message: %v", entry["error"])
+	}
+	// The innermost stack points at where the error was created
+	st, _ := entry["error_stack"].(string)
+	if !strings.HasPrefix(st, "github.com/localhots/blip/pkgerrors.newError\n\t") {
+		t.Errorf("expected stack trace to start where the error was created, got:\n%s", st)
+	}
+}
+
+func TestCauseWithKey(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf)
+	logger.Error(context.Background(), "Task failed", CauseWithKey("err", newError()))
+
+	entry := decode(t, &buf)
+	if entry["err"] != "task failed" {
+		t.Errorf("unexpected error message: %v", entry["err"])
+	}
+	if _, ok := entry["err_stack"]; !ok {
+		t.Errorf("expected a stack trace under err_stack, got %v", entry)
+	}
+}
+
+func TestCauseWithoutStack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf)
+	logger.Error(context.Background(), "Task failed", Cause(errors.New("task failed")))
+
+	entry := decode(t, &buf)
+	if entry["error"] != "task failed" {
+		t.Errorf("unexpected error message: %v", entry["error"])
+	}
+	if _, ok := entry["error_stack"]; ok {
+		t.Errorf("unexpected error stack: %v", entry["error_stack"])
+	}
+}
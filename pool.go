@@ -0,0 +1,167 @@
+package blip
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// pools holds the buffers and field slices reused by loggers to reduce
+// allocations.
+type pools struct {
+	buffers sync.Pool
+	fields  sync.Pool
+	// shards replace the buffer pool when set, see newShardedPools.
+	shards []bufferShard
+	next   atomic.Uint32
+	// allocs counts buffers allocated by the pool.
+	allocs atomic.Uint64
+	// reused counts buffers taken from the pool once countReused is set by
+	// StartStatsReporter, which keeps the atomic operation off the path of
+	// loggers without a reporter.
+	reused      atomic.Uint64
+	countReused atomic.Bool
+}
+
+// PoolStats describes how buffers are allocated by a logger.
+type PoolStats struct {
+	// Allocated is the number of buffers allocated because the pool had no
+	// idle buffers. Comparing it to the number of entries logged, see
+	// Counts, tells how well buffers are reused.
+	Allocated uint64
+	// Reused is the number of buffers taken from the pool instead of being
+	// allocated. It is only counted after StartStatsReporter is called on a
+	// logger using the pools.
+	Reused uint64
+}
+
+// bufferShard is a small free list of buffers. Shards are padded to separate
+// cache lines so that goroutines using neighboring shards don't contend.
+type bufferShard struct {
+	lock sync.Mutex
+	free []*Buffer
+	_    [64]byte
+}
+
+// maxShardBuffers is the number of idle buffers a shard keeps. Buffers put
+// into a full shard are left for garbage collection.
+const maxShardBuffers = 8
+
+// sharedPools are used by all loggers without private pools.
+var sharedPools = newPools()
+
+func newPools() *pools {
+	p := &pools{}
+	// Preallocated slices of 20 fields should be enough for most cases, in
+	// worst case the slice will grow.
+	p.fields.New = func() any {
+		fields := make([]Field, 0, 20)
+		return &fields
+	}
+	return p
+}
+
+// newShardedPools creates pools that keep buffers in free lists sharded by a
+// counter, one shard per P, instead of a sync.Pool. Goroutines start looking
+// for a buffer at different shards, so they rarely wait for each other.
+// Unlike a sync.Pool, the shards keep their buffers across garbage
+// collections.
+func newShardedPools() *pools {
+	p := newPools()
+	p.shards = make([]bufferShard, runtime.GOMAXPROCS(0))
+	return p
+}
+
+func (p *pools) getBuffer() *Buffer {
+	var buf *Buffer
+	if p.shards != nil {
+		buf = p.getShardBuffer()
+	} else {
+		buf, _ = p.buffers.Get().(*Buffer)
+	}
+	if buf == nil {
+		p.allocs.Add(1)
+		return &Buffer{make([]byte, 0, bufferSize)}
+	}
+	if p.countReused.Load() {
+		p.reused.Add(1)
+	}
+	return buf
+}
+
+// getShardBuffer takes a buffer from the first shard that has one, starting
+// from the next shard in a round-robin order and skipping locked shards. It
+// returns nil if no shard has an idle buffer.
+func (p *pools) getShardBuffer() *Buffer {
+	n := uint32(len(p.shards))
+	i := p.next.Add(1)
+	for range n {
+		s := &p.shards[i%n]
+		i++
+		if !s.lock.TryLock() {
+			continue
+		}
+		if k := len(s.free); k > 0 {
+			buf := s.free[k-1]
+			s.free = s.free[:k-1]
+			s.lock.Unlock()
+			return buf
+		}
+		s.lock.Unlock()
+	}
+	return nil
+}
+
+// putShardBuffer puts a buffer into the first shard that has room for it,
+// like getShardBuffer does.
+func (p *pools) putShardBuffer(buf *Buffer) {
+	n := uint32(len(p.shards))
+	i := p.next.Add(1)
+	for range n {
+		s := &p.shards[i%n]
+		i++
+		if !s.lock.TryLock() {
+			continue
+		}
+		if len(s.free) < maxShardBuffers {
+			s.free = append(s.free, buf)
+			s.lock.Unlock()
+			return
+		}
+		s.lock.Unlock()
+	}
+}
+
+func (p *pools) putBuffer(buf *Buffer) {
+	const maxCap = 10 * bufferSize
+	if cap(buf.b) > maxCap {
+		// If the buffer is too large, let it get garbage collected.
+		// This avoids keeping large buffers in the pool to reduce memory usage.
+		return
+	}
+	buf.b = buf.b[:0] // Reset the underlying slice
+	if p.shards != nil {
+		p.putShardBuffer(buf)
+		return
+	}
+	p.buffers.Put(buf)
+}
+
+func (p *pools) stats() PoolStats {
+	return PoolStats{
+		Allocated: p.allocs.Load(),
+		Reused:    p.reused.Load(),
+	}
+}
+
+func (p *pools) getFields() *[]Field {
+	return p.fields.Get().(*[]Field)
+}
+
+func (p *pools) putFields(fields *[]Field) {
+	if fields == nil {
+		return
+	}
+	*fields = (*fields)[:0] // Reset
+	p.fields.Put(fields)
+}
@@ -0,0 +1,55 @@
+package blip
+
+import "testing"
+
+func TestPoolStats(t *testing.T) {
+	p := newPools()
+	buf := p.getBuffer()
+	p.putBuffer(buf)
+	// The sync.Pool may drop the buffer, so it may be allocated again
+	_ = p.getBuffer()
+	if stats := p.stats(); stats.Allocated < 1 || stats.Allocated > 2 || stats.Reused != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Reused buffers are only counted once requested
+	before := p.stats()
+	p.countReused.Store(true)
+	for range 10 {
+		p.putBuffer(p.getBuffer())
+	}
+	if stats := p.stats(); stats.Allocated-before.Allocated+stats.Reused != 10 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestShardedPools(t *testing.T) {
+	p := newShardedPools()
+	buf := p.getBuffer()
+	buf.WriteString("hello")
+	p.putBuffer(buf)
+
+	var reused bool
+	for range len(p.shards) {
+		b := p.getBuffer()
+		if b.Len() != 0 {
+			t.Fatalf("expected an empty buffer, got %q", b.Bytes())
+		}
+		reused = reused || b == buf
+	}
+	if !reused {
+		t.Error("expected the buffer to be reused")
+	}
+
+	// Shards keep a limited number of buffers
+	for range len(p.shards)*maxShardBuffers + 1 {
+		p.putBuffer(&Buffer{make([]byte, 0, bufferSize)})
+	}
+	var total int
+	for i := range p.shards {
+		total += len(p.shards[i].free)
+	}
+	if total != len(p.shards)*maxShardBuffers {
+		t.Errorf("expected %d idle buffers, got %d", len(p.shards)*maxShardBuffers, total)
+	}
+}
@@ -0,0 +1,264 @@
+// Package protobuf provides an encoder that writes log entries as LogEntry
+// protocol buffer messages. The schema is defined in logentry.proto.
+package protobuf
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative logentry.proto
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/localhots/blip"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// LogEntry field numbers.
+const (
+	fieldTime       protowire.Number = 1
+	fieldLevel      protowire.Number = 2
+	fieldMessage    protowire.Number = 3
+	fieldFields     protowire.Number = 4
+	fieldStackTrace protowire.Number = 5
+)
+
+// Field field numbers.
+const (
+	fieldKey         protowire.Number = 1
+	fieldStringValue protowire.Number = 2
+	fieldIntValue    protowire.Number = 3
+	fieldDoubleValue protowire.Number = 4
+	fieldBoolValue   protowire.Number = 5
+	fieldBytesValue  protowire.Number = 6
+)
+
+// ProtoEncoder is an encoder that encodes log entries as LogEntry messages.
+// Each entry is prefixed with its size encoded as a varint, which makes the
+// output a stream of length-delimited messages that can be read with
+// protodelim.UnmarshalFrom.
+type ProtoEncoder struct{}
+
+var _ blip.EntryEncoder = (*ProtoEncoder)(nil)
+
+// NewProtoEncoder creates a new protobuf encoder.
+func NewProtoEncoder() *ProtoEncoder {
+	return &ProtoEncoder{}
+}
+
+// Encode encodes a whole entry with the time it was logged at.
+func (e *ProtoEncoder) Encode(buf *blip.Buffer, entry blip.Entry) {
+	e.Start(buf)
+	writeTime(buf, entry.Time)
+	e.EncodeLevel(buf, entry.Level)
+	e.EncodeMessage(buf, entry.Message)
+	e.EncodeFields(buf, entry.Level, entry.Fields)
+	if entry.StackTrace != "" {
+		writeString(buf, fieldStackTrace, entry.StackTrace)
+	}
+	e.End(buf)
+}
+
+// Start writes the beginning of the log message.
+func (e *ProtoEncoder) Start(_ *blip.Buffer) {}
+
+// EncodeTime encodes the current time as the time of the log message.
+func (e *ProtoEncoder) EncodeTime(buf *blip.Buffer) {
+	writeTime(buf, time.Now())
+}
+
+// EncodeLevel encodes the log level of the message.
+func (e *ProtoEncoder) EncodeLevel(buf *blip.Buffer, lev blip.Level) {
+	writeTag(buf, fieldLevel, protowire.VarintType)
+	writeVarint(buf, uint64(lev))
+}
+
+// EncodeMessage encodes the log message.
+func (e *ProtoEncoder) EncodeMessage(buf *blip.Buffer, msg string) {
+	writeString(buf, fieldMessage, msg)
+}
+
+// EncodeFields encodes the fields of the log message.
+func (e *ProtoEncoder) EncodeFields(buf *blip.Buffer, _ blip.Level, fields *[]blip.Field) {
+	if fields == nil {
+		return
+	}
+	for _, f := range *fields {
+		writeField(buf, f)
+	}
+}
+
+// EncodeStackTrace encodes the stack trace of the log message.
+func (e *ProtoEncoder) EncodeStackTrace(buf *blip.Buffer, skip int) {
+	writeString(buf, fieldStackTrace, blip.StackTrace(skip+1))
+}
+
+// End writes the end of the log message. It prefixes the message with its
+// size.
+func (e *ProtoEncoder) End(buf *blip.Buffer) {
+	n := buf.Len()
+	var scratch [maxVarintLen]byte
+	prefix := protowire.AppendVarint(scratch[:0], uint64(n))
+
+	// Make room for the prefix and move the message after it
+	buf.WriteBytes(prefix...)
+	b := buf.Bytes()
+	copy(b[len(prefix):], b[:n])
+	copy(b, prefix)
+}
+
+//
+// Helpers
+//
+
+const maxVarintLen = 10
+
+func writeVarint(buf *blip.Buffer, v uint64) {
+	var scratch [maxVarintLen]byte
+	buf.WriteBytes(protowire.AppendVarint(scratch[:0], v)...)
+}
+
+func writeTag(buf *blip.Buffer, num protowire.Number, typ protowire.Type) {
+	writeVarint(buf, protowire.EncodeTag(num, typ))
+}
+
+// writeTime writes the time as a google.protobuf.Timestamp.
+func writeTime(buf *blip.Buffer, t time.Time) {
+	secs, nanos := uint64(t.Unix()), uint64(t.Nanosecond())
+	writeTag(buf, fieldTime, protowire.BytesType)
+	writeVarint(buf, uint64(
+		protowire.SizeTag(1)+protowire.SizeVarint(secs)+
+			protowire.SizeTag(2)+protowire.SizeVarint(nanos)))
+	writeTag(buf, 1, protowire.VarintType)
+	writeVarint(buf, secs)
+	writeTag(buf, 2, protowire.VarintType)
+	writeVarint(buf, nanos)
+}
+
+func writeString(buf *blip.Buffer, num protowire.Number, s string) {
+	writeTag(buf, num, protowire.BytesType)
+	writeVarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// value is a field value converted to one of the types supported by the
+// schema.
+type value struct {
+	num protowire.Number // zero if the value is not set
+	str string
+	u64 uint64 // ints, doubles and bools
+	raw []byte
+}
+
+func (v value) size() int {
+	switch v.num {
+	case 0:
+		return 0
+	case fieldStringValue:
+		return protowire.SizeTag(v.num) + protowire.SizeBytes(len(v.str))
+	case fieldBytesValue:
+		return protowire.SizeTag(v.num) + protowire.SizeBytes(len(v.raw))
+	case fieldDoubleValue:
+		return protowire.SizeTag(v.num) + protowire.SizeFixed64()
+	default:
+		return protowire.SizeTag(v.num) + protowire.SizeVarint(v.u64)
+	}
+}
+
+func (v value) write(buf *blip.Buffer) {
+	switch v.num {
+	case 0:
+	case fieldStringValue:
+		writeString(buf, v.num, v.str)
+	case fieldBytesValue:
+		writeTag(buf, v.num, protowire.BytesType)
+		writeVarint(buf, uint64(len(v.raw)))
+		buf.WriteBytes(v.raw...)
+	case fieldDoubleValue:
+		var scratch [8]byte
+		writeTag(buf, v.num, protowire.Fixed64Type)
+		buf.WriteBytes(protowire.AppendFixed64(scratch[:0], v.u64)...)
+	default:
+		writeTag(buf, v.num, protowire.VarintType)
+		writeVarint(buf, v.u64)
+	}
+}
+
+func writeField(buf *blip.Buffer, f blip.Field) {
+	v := convert(f.Value)
+	writeTag(buf, fieldFields, protowire.BytesType)
+	writeVarint(buf, uint64(protowire.SizeTag(fieldKey)+protowire.SizeBytes(len(f.Key))+v.size()))
+	writeString(buf, fieldKey, f.Key)
+	v.write(buf)
+}
+
+func intValue(i int64) value {
+	return value{num: fieldIntValue, u64: uint64(i)}
+}
+
+func doubleValue(f float64) value {
+	return value{num: fieldDoubleValue, u64: math.Float64bits(f)}
+}
+
+func stringValue(s string) value {
+	return value{num: fieldStringValue, str: s}
+}
+
+// nolint:gocyclo
+func convert(val any) value {
+	switch v := val.(type) {
+	case string:
+		return stringValue(v)
+	case []byte:
+		return value{num: fieldBytesValue, raw: v}
+	case nil:
+		return value{}
+	case bool:
+		return value{num: fieldBoolValue, u64: protowire.EncodeBool(v)}
+	case int:
+		return intValue(int64(v))
+	case int8:
+		return intValue(int64(v))
+	case int16:
+		return intValue(int64(v))
+	case int32:
+		return intValue(int64(v))
+	case int64:
+		return intValue(v)
+	case uint:
+		return uintValue(uint64(v))
+	case uint8:
+		return intValue(int64(v))
+	case uint16:
+		return intValue(int64(v))
+	case uint32:
+		return intValue(int64(v))
+	case uint64:
+		return uintValue(v)
+	case float32:
+		return doubleValue(float64(v))
+	case float64:
+		return doubleValue(v)
+	case time.Duration:
+		return stringValue(v.Truncate(blip.DurationFieldPrecision).String())
+	case time.Time:
+		return stringValue(v.Format(blip.TimeFieldFormat))
+	case blip.LogValuer:
+		return convert(blip.ResolveLogValue(v))
+	case error:
+		return stringValue(v.Error())
+	default:
+		if b, err := json.Marshal(v); err == nil {
+			return stringValue(string(b))
+		}
+		return stringValue(fmt.Sprint(v))
+	}
+}
+
+// uintValue converts unsigned integers that overflow int64 to strings.
+func uintValue(u uint64) value {
+	if u > math.MaxInt64 {
+		return stringValue(fmt.Sprint(u))
+	}
+	return intValue(int64(u))
+}
@@ -0,0 +1,131 @@
+package protobuf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/localhots/blip"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoEncoderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	logger := blip.New(blip.Config{
+		Level:           blip.LevelDebug,
+		Output:          &buf,
+		Encoder:         NewProtoEncoder(),
+		StackTraceLevel: blip.LevelError,
+	})
+	ctx := context.Background()
+
+	before := time.Now()
+	logger.Debug(ctx, "Starting task")
+	logger.Info(ctx, "Task progress", blip.F{
+		"string": "foo",
+		"int":    -42,
+		"uint":   uint64(math.MaxUint64),
+		"float":  1.5,
+		"bool":   true,
+		"bytes":  []byte{0, 1, 2},
+		"nil":    nil,
+		"error":  errors.New("oops"),
+	})
+	logger.Error(ctx, "Task failed")
+	after := time.Now()
+
+	entries := readEntries(t, &buf)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	for _, e := range entries {
+		ts := e.GetTime().AsTime()
+		if ts.Before(before.Truncate(time.Second)) || ts.After(after) {
+			t.Errorf("unexpected time %v", ts)
+		}
+	}
+
+	exp := []struct {
+		level Level
+		msg   string
+	}{
+		{Level_LEVEL_DEBUG, "Starting task"},
+		{Level_LEVEL_INFO, "Task progress"},
+		{Level_LEVEL_ERROR, "Task failed"},
+	}
+	for i, e := range entries {
+		if e.GetLevel() != exp[i].level || e.GetMessage() != exp[i].msg {
+			t.Errorf("entry %d: expected %v %q, got %v %q", i, exp[i].level, exp[i].msg, e.GetLevel(), e.GetMessage())
+		}
+	}
+
+	fields := map[string]*Field{}
+	for _, f := range entries[1].GetFields() {
+		fields[f.GetKey()] = f
+	}
+	expFields := map[string]*Field{
+		"string": {Key: "string", Value: &Field_StringValue{StringValue: "foo"}},
+		"int":    {Key: "int", Value: &Field_IntValue{IntValue: -42}},
+		"uint":   {Key: "uint", Value: &Field_StringValue{StringValue: "18446744073709551615"}},
+		"float":  {Key: "float", Value: &Field_DoubleValue{DoubleValue: 1.5}},
+		"bool":   {Key: "bool", Value: &Field_BoolValue{BoolValue: true}},
+		"bytes":  {Key: "bytes", Value: &Field_BytesValue{BytesValue: []byte{0, 1, 2}}},
+		"nil":    {Key: "nil"},
+		"error":  {Key: "error", Value: &Field_StringValue{StringValue: "oops"}},
+	}
+	if len(fields) != len(expFields) {
+		t.Errorf("expected %d fields, got %d", len(expFields), len(fields))
+	}
+	for k, exp := range expFields {
+		if !proto.Equal(fields[k], exp) {
+			t.Errorf("field %q: expected %v, got %v", k, exp, fields[k])
+		}
+	}
+
+	if entries[1].GetStackTrace() != "" {
+		t.Errorf("unexpected stack trace: %q", entries[1].GetStackTrace())
+	}
+	if !strings.Contains(entries[2].GetStackTrace(), "TestProtoEncoderRoundTrip") {
+		t.Errorf("expected stack trace to contain the test function, got %q", entries[2].GetStackTrace())
+	}
+}
+
+func TestProtoEncoderLargeEntry(t *testing.T) {
+	var buf bytes.Buffer
+	logger := blip.New(blip.Config{Output: &buf, Encoder: NewProtoEncoder()})
+
+	// Messages over 127 bytes need a multi-byte length prefix
+	msg := strings.Repeat("x", 10000)
+	logger.Info(context.Background(), msg)
+	logger.Info(context.Background(), "short")
+
+	entries := readEntries(t, &buf)
+	if len(entries) != 2 || entries[0].GetMessage() != msg || entries[1].GetMessage() != "short" {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+}
+
+func readEntries(t *testing.T, r io.Reader) []*LogEntry {
+	t.Helper()
+	br := bufio.NewReader(r)
+	var entries []*LogEntry
+	for {
+		var e LogEntry
+		err := protodelim.UnmarshalFrom(br, &e)
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("failed to read entry %d: %v", len(entries), err)
+		}
+		entries = append(entries, &e)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/localhots/blip/protobuf
+
+go 1.23.4
+
+require (
+	github.com/localhots/blip v0.0.0
+	google.golang.org/protobuf v1.34.2
+)
+
+replace github.com/localhots/blip => ../
@@ -0,0 +1,156 @@
+package blip
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder is an encoder that records the entries it encodes, so they can be
+// inspected in tests or replayed into another logger later, e.g. to only log
+// the details of a task if it fails:
+//
+//	rec := &blip.Recorder{}
+//	taskLogger := blip.New(blip.Config{Output: io.Discard, Encoder: rec, Level: blip.LevelDebug})
+//	if err := runTask(ctx, taskLogger); err != nil {
+//		rec.Replay(logger)
+//	}
+//
+// Entries are recorded with copies of their fields, the field values are
+// shared. If Encoder is set, entries are also encoded with it, otherwise
+// nothing is written to the output. It is safe for concurrent use.
+type Recorder struct {
+	Encoder
+	// MaxEntries limits the number of recorded entries, the oldest entries are
+	// dropped to make room for new ones. Zero means no limit. It must not be
+	// changed once entries are recorded.
+	MaxEntries int
+
+	lock sync.Mutex
+	// entries is a ring buffer once MaxEntries is reached, with the oldest
+	// entry at next
+	entries []Entry
+	next    int
+}
+
+var _ EntryEncoder = (*Recorder)(nil)
+
+// Encode records the entry and encodes it with the wrapped encoder, if any.
+func (r *Recorder) Encode(buf *Buffer, e Entry) {
+	if e.Fields != nil {
+		fields := make([]Field, len(*e.Fields))
+		copy(fields, *e.Fields)
+		e.Fields = &fields
+	}
+	r.lock.Lock()
+	if r.MaxEntries > 0 && len(r.entries) >= r.MaxEntries {
+		r.entries[r.next] = e
+		r.next = (r.next + 1) % len(r.entries)
+	} else {
+		r.entries = append(r.entries, e)
+	}
+	r.lock.Unlock()
+
+	if r.Encoder != nil {
+		Encode(r.Encoder, buf, e)
+	}
+}
+
+// Entries returns the recorded entries in the order they were encoded.
+func (r *Recorder) Entries() []Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.ordered()
+}
+
+// ordered returns a copy of the recorded entries, oldest first.
+func (r *Recorder) ordered() []Entry {
+	if len(r.entries) == 0 {
+		return nil
+	}
+	entries := make([]Entry, 0, len(r.entries))
+	entries = append(entries, r.entries[r.next:]...)
+	return append(entries, r.entries[:r.next]...)
+}
+
+// Reset removes the recorded entries.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	r.entries = nil
+	r.next = 0
+	r.lock.Unlock()
+}
+
+// Replay logs the recorded entries with the given logger, keeping their time,
+// name, fields, and stack traces. Entries are filtered by the level and the
+// sampler of the logger, but panic and fatal entries don't panic or exit. The
+// fields of the logger are not added, its ValueRedactor and sequence numbers
+// are applied to copies of the recorded fields. It returns the first error
+// returned by the output of the logger.
+func (r *Recorder) Replay(l *Logger) error {
+	return replay(l, r.Entries(), true)
+}
+
+// take returns the recorded entries and removes them from the recorder.
+func (r *Recorder) take() []Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entries := r.entries
+	if r.next != 0 {
+		entries = r.ordered()
+	}
+	r.entries = nil
+	r.next = 0
+	return entries
+}
+
+// replay writes entries to the output of the logger, filtering them like the
+// logger does if filter is set.
+func replay(l *Logger, entries []Entry, filter bool) error {
+	var err error
+	for _, e := range entries {
+		if filter && !l.enabled(context.Background(), e.Level) || l.discard.Load() {
+			continue
+		}
+		if l.closed.Load() {
+			l.dropped.Add(1)
+			if err == nil {
+				err = ErrClosed
+			}
+			continue
+		}
+		l.counts[e.Level].Add(1)
+		buf := l.pools.getBuffer()
+		var fields *[]Field
+		if l.cfg.IncludeSequence || l.cfg.ValueRedactor != nil {
+			// Recorded fields are shared by replays, they are copied before
+			// they are changed
+			fields = l.pools.getFields()
+			if e.Fields != nil {
+				*fields = append(*fields, *e.Fields...)
+			}
+			l.prepareReplay(fields, &e)
+			e.Fields = fields
+		}
+		if werr := l.write(buf, e); werr != nil && err == nil {
+			err = werr
+		}
+		l.pools.putFields(fields)
+		l.pools.putBuffer(buf)
+	}
+	return err
+}
+
+// prepareReplay adds the sequence number placeholder to the copied fields of
+// a replayed entry and applies the redactor, like print does.
+func (l *Logger) prepareReplay(fields *[]Field, e *Entry) {
+	if l.cfg.IncludeSequence {
+		// Assigned by write, in the order entries are written
+		addField(fields, nil, l.cfg.SequenceKey, uint64(0))
+	}
+	if l.cfg.ValueRedactor != nil {
+		redactFields(fields, l.cfg.ValueRedactor)
+		if l.cfg.RedactMessages {
+			e.Message = l.cfg.ValueRedactor(e.Message)
+		}
+	}
+}
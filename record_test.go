@@ -0,0 +1,147 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	rec := &Recorder{}
+	logger := New(Config{Output: io.Discard, Encoder: rec, Level: LevelDebug, StackTraceLevel: LevelPanic})
+	ctx := context.Background()
+	logger.Debug(ctx, "Starting task", F{"task_id": 1})
+	logger.Error(ctx, "Task failed", F{"task_id": 1}, Cause(errors.New("timeout")))
+
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	e := entries[1]
+	if e.Level != LevelError || e.Message != "Task failed" || !e.Time.Equal(now) {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	// Fields are copied from pooled slices that are reused by later entries
+	logger.Info(ctx, "Unrelated", F{"other": true})
+	if fields := *entries[0].Fields; len(fields) != 1 || fields[0].Key != "task_id" || fields[0].Value != 1 {
+		t.Errorf("expected recorded fields to be unchanged, got %v", fields)
+	}
+
+	var buf bytes.Buffer
+	timeNow = func() time.Time { return now.Add(time.Hour) }
+	target := New(Config{Output: &buf, Encoder: &JSONEncoder{
+		KeyTime:    "time",
+		KeyLevel:   "level",
+		KeyMessage: "message",
+		TimeFormat: time.RFC3339,
+		Location:   time.UTC,
+		SortFields: true,
+	}})
+	if err := rec.Replay(target); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The debug entry is below the level of the target logger
+	exp := `{"time":"2025-01-02T03:04:05Z","level":"error","message":"Task failed","error":"timeout","task_id":1}` + "\n" +
+		`{"time":"2025-01-02T03:04:05Z","level":"info","message":"Unrelated","other":true}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+
+	rec.Reset()
+	if n := len(rec.Entries()); n != 0 {
+		t.Errorf("expected no entries after reset, got %d", n)
+	}
+}
+
+func TestRecorderReplaySequenceRedactor(t *testing.T) {
+	rec := &Recorder{}
+	logger := New(Config{Output: io.Discard, Encoder: rec, IncludeSequence: true})
+	ctx := context.Background()
+	logger.Info(ctx, "Login with hunter2", F{"password": "hunter2"})
+	logger.Info(ctx, "Logout")
+
+	var buf bytes.Buffer
+	target := New(Config{
+		Output:          &buf,
+		Encoder:         &ConsoleEncoder{SortFields: true},
+		IncludeSequence: true,
+		ValueRedactor:   func(s string) string { return strings.ReplaceAll(s, "hunter2", "***") },
+		RedactMessages:  true,
+	})
+	for range 2 {
+		if err := rec.Replay(target); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	exp := "INFO Login with ***  password=*** seq=1\n" +
+		"INFO Logout  seq=2\n" +
+		"INFO Login with ***  password=*** seq=3\n" +
+		"INFO Logout  seq=4\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+
+	// Recorded entries keep their own values
+	entries := rec.Entries()
+	if e := entries[0]; e.Message != "Login with hunter2" || fieldValue(e, "password") != "hunter2" || fieldValue(e, "seq") != uint64(1) {
+		t.Errorf("expected the recorded entry to be unchanged, got %+v %v", e, *e.Fields)
+	}
+	if e := entries[1]; fieldValue(e, "seq") != uint64(2) {
+		t.Errorf("expected the recorded entry to be unchanged, got %v", *e.Fields)
+	}
+}
+
+func fieldValue(e Entry, key string) any {
+	for _, f := range *e.Fields {
+		if f.Key == key {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+func TestRecorderEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	rec := &Recorder{Encoder: &ConsoleEncoder{}}
+	logger := New(Config{Output: &buf, Encoder: rec})
+	logger.Info(context.Background(), "Hello", F{"a": 1})
+	if exp := "INFO Hello  a=1\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+	if n := len(rec.Entries()); n != 1 {
+		t.Errorf("expected 1 entry, got %d", n)
+	}
+}
+
+func TestRecorderMaxEntries(t *testing.T) {
+	rec := &Recorder{MaxEntries: 3}
+	logger := New(Config{Output: io.Discard, Encoder: rec})
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		logger.Info(context.Background(), msg)
+	}
+
+	var msgs []string
+	for _, e := range rec.Entries() {
+		msgs = append(msgs, e.Message)
+	}
+	if !slices.Equal(msgs, []string{"three", "four", "five"}) {
+		t.Errorf("expected the last 3 entries oldest first, got %v", msgs)
+	}
+
+	var buf bytes.Buffer
+	if err := rec.Replay(New(Config{Output: &buf, Encoder: &ConsoleEncoder{}})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp := "INFO three\nINFO four\nINFO five\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
@@ -0,0 +1,216 @@
+package blip
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SizeRotatingWriter is a file writer that rotates the file once it reaches
+// the maximum size. The rotated file is renamed with the time of rotation
+// appended to its name, e.g. app.log.2006-01-02T15-04-05.000000000, and a new
+// file is created in its place. It is safe for concurrent use and can be used
+// as the logger output.
+type SizeRotatingWriter struct {
+	// Filename is the file to write to.
+	Filename string
+	// MaxSize is the size in bytes after which the file is rotated. Zero
+	// disables rotation.
+	MaxSize int64
+	// MaxBackups is the number of rotated files to keep. Zero keeps all
+	// files.
+	MaxBackups int
+	// MaxAge is the age after which rotated files are removed. Zero keeps all
+	// files.
+	MaxAge time.Duration
+	// OnRemoveError is called with the error of removing rotated files after
+	// a write rotated the file. The write itself succeeds. Defaults to
+	// printing the error to stderr.
+	OnRemoveError func(error)
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// removeFile removes a rotated file. Replaced in tests.
+var removeFile = os.Remove
+
+// NewSizeRotatingWriter creates a writer that rotates the file once it grows
+// larger than maxSize bytes. The file is opened on the first write.
+func NewSizeRotatingWriter(filename string, maxSize int64) *SizeRotatingWriter {
+	return &SizeRotatingWriter{
+		Filename: filename,
+		MaxSize:  maxSize,
+	}
+}
+
+// Write implements the io.Writer interface. The file is rotated before the
+// write if the data doesn't fit into it. Data is never split between files,
+// writes larger than the maximum size go to a fresh file. Rotated files are
+// removed after the data is written, see OnRemoveError.
+func (w *SizeRotatingWriter) Write(b []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	var rotated bool
+	if w.MaxSize > 0 && w.size > 0 && w.size+int64(len(b)) > w.MaxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+		rotated = true
+	}
+
+	n, err := w.file.Write(b)
+	w.size += int64(n)
+	if rotated {
+		if rerr := w.removeBackups(); rerr != nil {
+			w.removeError(rerr)
+		}
+	}
+	return n, err
+}
+
+// Rotate rotates the file regardless of its size. Errors of removing rotated
+// files are returned along with rotation errors.
+func (w *SizeRotatingWriter) Rotate() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.rotate(); err != nil {
+		return err
+	}
+	return w.removeBackups()
+}
+
+// Sync commits the contents of the file to stable storage.
+func (w *SizeRotatingWriter) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the file. A subsequent write reopens it.
+func (w *SizeRotatingWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *SizeRotatingWriter) open() error {
+	f, err := os.OpenFile(w.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.file = f
+	w.size = fi.Size()
+	return nil
+}
+
+func (w *SizeRotatingWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+
+	backup := w.Filename + "." + timeNow().Format(backupTimeFormat)
+	if err := os.Rename(w.Filename, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return w.open()
+}
+
+func (w *SizeRotatingWriter) removeError(err error) {
+	if w.OnRemoveError != nil {
+		w.OnRemoveError(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "blip: removing rotated files: %v\n", err)
+}
+
+// removeBackups removes rotated files exceeding MaxBackups or older than
+// MaxAge, oldest first.
+func (w *SizeRotatingWriter) removeBackups() error {
+	if w.MaxBackups == 0 && w.MaxAge == 0 {
+		return nil
+	}
+
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var remove []string
+	if w.MaxBackups > 0 && len(backups) > w.MaxBackups {
+		remove = backups[:len(backups)-w.MaxBackups]
+		backups = backups[len(backups)-w.MaxBackups:]
+	}
+	if w.MaxAge > 0 {
+		cutoff := timeNow().Add(-w.MaxAge)
+		prefix := filepath.Base(w.Filename) + "."
+		for _, b := range backups {
+			t, _ := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(filepath.Base(b), prefix), time.Local)
+			if t.Before(cutoff) {
+				remove = append(remove, b)
+			}
+		}
+	}
+
+	var errs []error
+	for _, b := range remove {
+		if err := removeFile(b); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backups returns the paths of rotated files, oldest first.
+func (w *SizeRotatingWriter) backups() ([]string, error) {
+	dir := filepath.Dir(w.Filename)
+	prefix := filepath.Base(w.Filename) + "."
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(name, prefix)); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	// Time formatted names sort chronologically
+	slices.Sort(backups)
+	return backups, nil
+}
@@ -0,0 +1,177 @@
+package blip
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSizeRotatingWriter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	w := NewSizeRotatingWriter(filename, 100)
+	defer w.Close()
+
+	line := strings.Repeat("a", 39) + "\n"
+	for range 5 {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 lines per file
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	for _, b := range backups {
+		if data, _ := os.ReadFile(b); string(data) != line+line {
+			t.Errorf("expected 2 lines in %s, got %q", b, data)
+		}
+	}
+	if data, _ := os.ReadFile(filename); string(data) != line {
+		t.Errorf("expected 1 line in the current file, got %q", data)
+	}
+}
+
+func TestSizeRotatingWriterAppend(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(filename, []byte("existing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewSizeRotatingWriter(filename, 10)
+	defer w.Close()
+	if _, err := w.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "new\n" {
+		t.Errorf("expected the existing file to be rotated, got %q", data)
+	}
+	if backups, _ := w.backups(); len(backups) != 1 {
+		t.Errorf("expected 1 backup, got %v", backups)
+	}
+}
+
+func TestSizeRotatingWriterMaxBackups(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	w := NewSizeRotatingWriter(filename, 10)
+	w.MaxBackups = 2
+	defer w.Close()
+
+	for _, s := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, _ := w.backups()
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	for i, exp := range []string{"four\n", "five\n"} {
+		if data, _ := os.ReadFile(backups[i]); string(data) != exp {
+			t.Errorf("expected backup %d to contain %q, got %q", i, exp, data)
+		}
+	}
+}
+
+func TestSizeRotatingWriterMaxAge(t *testing.T) {
+	now := time.Now()
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	filename := filepath.Join(t.TempDir(), "app.log")
+	w := NewSizeRotatingWriter(filename, 0)
+	w.MaxAge = time.Hour
+	defer w.Close()
+
+	_, _ = w.Write([]byte("old\n"))
+	_ = w.Rotate()
+	now = now.Add(2 * time.Hour)
+	_, _ = w.Write([]byte("new\n"))
+	_ = w.Rotate()
+
+	backups, _ := w.backups()
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "new\n" {
+		t.Errorf("expected the old backup to be removed, got %q", data)
+	}
+}
+
+func TestSizeRotatingWriterRemoveError(t *testing.T) {
+	defer func() { removeFile = os.Remove }()
+	removeFile = func(string) error { return os.ErrPermission }
+
+	filename := filepath.Join(t.TempDir(), "app.log")
+	w := NewSizeRotatingWriter(filename, 10)
+	w.MaxBackups = 1
+	var errs []error
+	w.OnRemoveError = func(err error) { errs = append(errs, err) }
+	defer w.Close()
+
+	for _, s := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "fourth\n" {
+		t.Errorf("expected the data to be written, got %q", data)
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], os.ErrPermission) {
+		t.Errorf("expected 2 remove errors, got %v", errs)
+	}
+	if err := w.Rotate(); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected Rotate to return the remove error, got %v", err)
+	}
+}
+
+func TestSizeRotatingWriterConcurrent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	w := NewSizeRotatingWriter(filename, 1000)
+	defer w.Close()
+	logger := New(Config{Output: w, Encoder: NewJSONEncoder()})
+	ctx := context.Background()
+
+	const goroutines, entries = 8, 100
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range entries {
+				logger.Info(ctx, "Task started", F{"task_id": 123})
+			}
+		}()
+	}
+	wg.Wait()
+	_ = w.Close()
+
+	backups, _ := w.backups()
+	lines := 0
+	for _, f := range append(backups, filename) {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 1000 {
+			t.Errorf("file %s exceeds the maximum size: %d bytes", f, len(data))
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != goroutines*entries {
+		t.Errorf("expected %d lines, got %d", goroutines*entries, lines)
+	}
+}
@@ -0,0 +1,49 @@
+package blip
+
+import "math/rand/v2"
+
+// Sampler decides whether a log entry should be written. It is consulted
+// after the level check and before any fields are assembled, so dropped
+// entries cost next to nothing.
+type Sampler interface {
+	// Sample reports whether an entry at the given level should be logged.
+	Sample(lev Level) bool
+}
+
+// LevelSampler is a Sampler that keeps entries with a configured probability
+// per level. Levels missing from the configuration are always kept. Error,
+// Panic, and Fatal entries are always kept regardless of the configuration.
+type LevelSampler struct {
+	rates [LevelFatal + 1]float64
+}
+
+var _ Sampler = (*LevelSampler)(nil)
+
+// NewLevelSampler creates a new LevelSampler from a map of keep probabilities,
+// ranging from 0 (drop everything) to 1 (keep everything).
+func NewLevelSampler(rates map[Level]float64) *LevelSampler {
+	var s LevelSampler
+	for lev := range s.rates {
+		s.rates[lev] = 1
+	}
+	for lev, rate := range rates {
+		if lev >= LevelTrace && lev < LevelError {
+			s.rates[lev] = rate
+		}
+	}
+	return &s
+}
+
+// Sample reports whether an entry at the given level should be logged.
+func (s *LevelSampler) Sample(lev Level) bool {
+	if lev < LevelTrace || lev >= LevelError {
+		return true
+	}
+	rate := s.rates[lev]
+	if rate >= 1 {
+		return true
+	}
+	// Top-level functions of math/rand/v2 use the cheap per-thread runtime
+	// generator and are safe for concurrent use.
+	return rand.Float64() < rate
+}
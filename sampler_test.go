@@ -0,0 +1,51 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestLevelSampler(t *testing.T) {
+	const n = 100_000
+
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.Output = &buf
+	cfg.Level = LevelTrace
+	cfg.Encoder = &ConsoleEncoder{}
+	cfg.Sampler = NewLevelSampler(map[Level]float64{
+		LevelDebug: 0.01,
+		LevelInfo:  0.1,
+		LevelError: 0, // Ignored, errors are always kept
+	})
+	logger := New(cfg)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		log  func(context.Context, string, ...F)
+		exp  int
+		tol  int
+	}{
+		// Tolerances are roughly 5 standard deviations of the binomial
+		// distribution for the given rate.
+		{"trace", logger.Trace, n, 0},
+		{"debug", logger.Debug, n / 100, 160},
+		{"info", logger.Info, n / 10, 480},
+		{"warn", logger.Warn, n, 0},
+		{"error", logger.Error, n, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			for range n {
+				tt.log(ctx, "Sampled")
+			}
+			kept := bytes.Count(buf.Bytes(), []byte{'\n'})
+			if kept < tt.exp-tt.tol || kept > tt.exp+tt.tol {
+				t.Errorf("expected %d±%d entries, got %d", tt.exp, tt.tol, kept)
+			}
+		})
+	}
+}
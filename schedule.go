@@ -0,0 +1,55 @@
+package blip
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LevelWindow sets the level of a logger for a period of time, e.g. to log
+// debug entries during a maintenance window. See Config.LevelSchedule.
+type LevelWindow struct {
+	Start time.Time
+	End   time.Time
+	Level Level
+}
+
+// levelSchedule looks up the level of the window active at a given time. The
+// result is cached for scheduleInterval.
+type levelSchedule struct {
+	windows []LevelWindow
+	last    atomic.Value // *scheduledLevel
+}
+
+type scheduledLevel struct {
+	t   time.Time
+	lev Level
+}
+
+// scheduleInterval is how often the schedule is evaluated.
+const scheduleInterval = time.Second
+
+func newLevelSchedule(windows []LevelWindow) *levelSchedule {
+	if len(windows) == 0 {
+		return nil
+	}
+	return &levelSchedule{windows: append([]LevelWindow(nil), windows...)}
+}
+
+// level returns the level of the window active at the given time, the most
+// verbose one if windows overlap, or zero if there is none.
+func (s *levelSchedule) level(now time.Time) Level {
+	if last, ok := s.last.Load().(*scheduledLevel); ok {
+		if d := now.Sub(last.t); d >= 0 && d < scheduleInterval {
+			return last.lev
+		}
+	}
+
+	var lev Level
+	for _, w := range s.windows {
+		if !now.Before(w.Start) && now.Before(w.End) && (lev == 0 || w.Level < lev) {
+			lev = w.Level
+		}
+	}
+	s.last.Store(&scheduledLevel{now, lev})
+	return lev
+}
@@ -0,0 +1,51 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLevelSchedule(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	var now time.Time
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	logger := New(Config{
+		Output:  &buf,
+		Encoder: &ConsoleEncoder{},
+		Level:   LevelInfo,
+		LevelSchedule: []LevelWindow{
+			{Start: start, End: start.Add(time.Hour), Level: LevelDebug},
+			// Quiet hours overlapping the maintenance window
+			{Start: start.Add(30 * time.Minute), End: start.Add(2 * time.Hour), Level: LevelError},
+		},
+	})
+	ctx := context.Background()
+
+	steps := []struct {
+		at  time.Duration
+		exp string
+	}{
+		{-400 * time.Millisecond, "INFO Info\n"},
+		// The schedule is evaluated at most once per second, so the window
+		// takes effect late
+		{500 * time.Millisecond, "INFO Info\n"},
+		{time.Second, "DEBU Debug\nINFO Info\n"},
+		{45 * time.Minute, "DEBU Debug\nINFO Info\n"},
+		{90 * time.Minute, ""},
+		{2 * time.Hour, "INFO Info\n"},
+	}
+	for _, s := range steps {
+		buf.Reset()
+		now = start.Add(s.at)
+		logger.Debug(ctx, "Debug")
+		logger.Info(ctx, "Info")
+		if buf.String() != s.exp {
+			t.Errorf("at %v: expected %q, got %q", s.at, s.exp, buf.String())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package blip
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// raise sends a signal to the current process. Replaced in tests.
+var raise = func(sig os.Signal) error {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// FlushOnSignal installs a handler that flushes the output of the logger when
+// the process receives one of the given signals, SIGINT and SIGTERM if none
+// are given, so that entries held by buffered writers are not lost on
+// shutdown. Outputs with a Flush method, like *bufio.Writer, are flushed, and
+// outputs with a Sync method, like *os.File, are synced.
+//
+// Once the output is flushed the handler uninstalls itself and raises the
+// signal again, so the process terminates as it would without the handler.
+// Programs that handle these signals themselves receive them twice, and
+// entries they log after the flush may remain buffered; they should flush the
+// output in their own handler instead.
+//
+// The returned function uninstalls the handler.
+func FlushOnSignal(l *Logger, sigs ...os.Signal) (cancel func()) {
+	return l.onSignal(false, sigs)
+}
+
+// CloseOnSignal is like FlushOnSignal, but also closes the output if it
+// implements io.Closer, e.g. to let an asynchronous writer drain its queue.
+func CloseOnSignal(l *Logger, sigs ...os.Signal) (cancel func()) {
+	return l.onSignal(true, sigs)
+}
+
+func (l *Logger) onSignal(closeOutput bool, sigs []os.Signal) func() {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-ch:
+			// There is nobody to report errors to at this point
+			_ = l.flushOutput(closeOutput)
+			signal.Stop(ch)
+			if err := raise(sig); err != nil {
+				exit(1)
+			}
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// flushOutput flushes, syncs, and optionally closes the output, depending on
+// the methods it implements.
+func (l *Logger) flushOutput(closeOutput bool) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var errs []error
+	if f, ok := l.out.(interface{ Flush() error }); ok {
+		errs = append(errs, f.Flush())
+	}
+	if s, ok := l.out.(interface{ Sync() error }); ok {
+		errs = append(errs, s.Sync())
+	}
+	if c, ok := l.out.(io.Closer); ok && closeOutput {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
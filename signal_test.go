@@ -0,0 +1,70 @@
+package blip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+type flushRecorder struct {
+	*bufio.Writer
+	synced, closed bool
+}
+
+func (r *flushRecorder) Sync() error  { r.synced = true; return nil }
+func (r *flushRecorder) Close() error { r.closed = true; return nil }
+
+func TestFlushOnSignal(t *testing.T) {
+	t.Run("flush", func(t *testing.T) { testOnSignal(t, FlushOnSignal, false) })
+	t.Run("close", func(t *testing.T) { testOnSignal(t, CloseOnSignal, true) })
+}
+
+func testOnSignal(t *testing.T, install func(*Logger, ...os.Signal) func(), closeOutput bool) {
+	var buf bytes.Buffer
+	out := &flushRecorder{Writer: bufio.NewWriter(&buf)}
+	logger := New(Config{Output: out, Encoder: &ConsoleEncoder{}})
+
+	raised := make(chan os.Signal, 1)
+	defer func(fn func(os.Signal) error) { raise = fn }(raise)
+	raise = func(sig os.Signal) error {
+		raised <- sig
+		return nil
+	}
+	cancel := install(logger, os.Interrupt)
+	defer cancel()
+
+	// The entry stays in the bufio.Writer until it is flushed
+	logger.Info(context.Background(), "Shutting down")
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("Failed to find process: %v", err)
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("Can't send signals: %v", err)
+	}
+	select {
+	case sig := <-raised:
+		if sig != os.Interrupt {
+			t.Errorf("expected %v to be raised again, got %v", os.Interrupt, sig)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal was not handled")
+	}
+
+	if exp := "INFO Shutting down\n"; buf.String() != exp {
+		t.Errorf("expected %q to be flushed, got %q", exp, buf.String())
+	}
+	if !out.synced {
+		t.Error("expected the output to be synced")
+	}
+	if out.closed != closeOutput {
+		t.Errorf("expected closed=%t, got %t", closeOutput, out.closed)
+	}
+
+	// Cancel is idempotent
+	cancel()
+}
@@ -0,0 +1,54 @@
+package blip
+
+import (
+	"context"
+	"time"
+)
+
+// newTicker returns a channel that receives ticks at the given interval and a
+// function that stops it. Replaced in tests.
+var newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// StartStatsReporter logs the stats of the logger at the given interval until
+// the context is canceled: the number of buffers allocated and reused, see
+// PoolStats, and the number of entries logged at each level, see Counts. Stats
+// are logged at Config.StatsLevel with the fields of the context, e.g.
+//
+//	INFO Logger stats buffers_allocated=12 buffers_reused=48090 ...
+//
+// Counters are totals since the logger was created, not since the previous
+// report, except reused buffers, which are counted from the first call to
+// StartStatsReporter. Intervals that aren't positive default to a minute.
+func (l *Logger) StartStatsReporter(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+	l.pools.countReused.Store(true)
+	ticks, stop := newTicker(interval)
+	go func() {
+		defer stop()
+		for {
+			select {
+			case <-ticks:
+				l.Log(ctx, l.cfg.StatsLevel, "Logger stats", l.stats())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (l *Logger) stats() F {
+	ps := l.PoolStats()
+	f := F{
+		"buffers_allocated": ps.Allocated,
+		"buffers_reused":    ps.Reused,
+	}
+	for lev := LevelTrace; lev <= LevelFatal; lev++ {
+		f["entries_"+lev.String()] = l.counts[lev].Load()
+	}
+	return f
+}
@@ -0,0 +1,80 @@
+package blip
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type notifyWriter chan string
+
+func (w notifyWriter) Write(b []byte) (int, error) {
+	w <- string(b)
+	return len(b), nil
+}
+
+func TestStartStatsReporter(t *testing.T) {
+	ticks := make(chan time.Time)
+	stopped := make(chan struct{})
+	defer func(fn func(time.Duration) (<-chan time.Time, func())) { newTicker = fn }(newTicker)
+	newTicker = func(time.Duration) (<-chan time.Time, func()) {
+		return ticks, func() { close(stopped) }
+	}
+
+	out := make(notifyWriter, 1)
+	logger := New(Config{
+		Output:       out,
+		Encoder:      &ConsoleEncoder{},
+		StatsLevel:   LevelWarn,
+		PrivatePools: true,
+	})
+	logger.Info(context.Background(), "Started")
+	<-out
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logger.StartStatsReporter(ctx, time.Minute)
+	ticks <- time.Now()
+	line := <-out
+	for _, exp := range []string{"WARN Logger stats", "buffers_allocated=", "buffers_reused=", "entries_info=1", "entries_error=0"} {
+		if !strings.Contains(line, exp) {
+			t.Errorf("expected %q in %q", exp, line)
+		}
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reporter did not stop")
+	}
+}
+
+func TestStartStatsReporterDefaults(t *testing.T) {
+	ticks := make(chan time.Time)
+	intervals := make(chan time.Duration, 1)
+	defer func(fn func(time.Duration) (<-chan time.Time, func())) { newTicker = fn }(newTicker)
+	newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		intervals <- d
+		return ticks, func() {}
+	}
+
+	out := make(notifyWriter, 1)
+	logger := New(Config{
+		Output:     out,
+		Encoder:    &ConsoleEncoder{},
+		StatsLevel: LevelFatal,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger.StartStatsReporter(ctx, 0)
+	if d := <-intervals; d != defaultStatsInterval {
+		t.Errorf("expected the default interval, got %v", d)
+	}
+
+	// Fatal would exit from the reporter goroutine
+	ticks <- time.Now()
+	if line := <-out; !strings.HasPrefix(line, "INFO Logger stats") {
+		t.Errorf("expected stats at the default level, got %q", line)
+	}
+}
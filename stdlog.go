@@ -0,0 +1,57 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// StdLogWriter returns a writer that logs every line written to it as a
+// separate entry at the given level. It can be used as the output of the
+// standard library logger, e.g. to funnel logs of third-party libraries:
+//
+//	log.New(logger.StdLogWriter(ctx, blip.LevelWarn, "redis"), "", 0)
+//
+// The source, if not empty, is added as a "source" field. Incomplete lines are
+// buffered until the rest of the line is written.
+func (l *Logger) StdLogWriter(ctx context.Context, lev Level, source string) io.Writer {
+	w := &stdLogWriter{l: l, ctx: ctx, lev: lev}
+	if source != "" {
+		w.fields = F{"source": source}
+	}
+	return w
+}
+
+type stdLogWriter struct {
+	l      *Logger
+	ctx    context.Context //nolint:containedctx // Used for every line logged
+	lev    Level
+	fields F
+
+	lock sync.Mutex
+	buf  []byte // Incomplete line
+}
+
+func (w *stdLogWriter) Write(b []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	n := len(b)
+	for {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			break
+		}
+		line := b[:i]
+		if len(w.buf) > 0 {
+			w.buf = append(w.buf, line...)
+			line = w.buf
+		}
+		w.l.Log(w.ctx, w.lev, string(bytes.TrimSuffix(line, []byte{'\r'})), w.fields)
+		w.buf = w.buf[:0]
+		b = b[i+1:]
+	}
+	w.buf = append(w.buf, b...)
+	return n, nil
+}
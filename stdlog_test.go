@@ -0,0 +1,38 @@
+package blip
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStdLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Output: &buf, Encoder: &ConsoleEncoder{}})
+	stdlog := log.New(logger.StdLogWriter(context.Background(), LevelWarn, "redis"), "", 0)
+
+	stdlog.Println("connection lost")
+	stdlog.Printf("reconnecting in %ds", 5)
+	exp := "WARN connection lost  source=redis\nWARN reconnecting in 5s  source=redis\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestStdLogWriterPartialLines(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Output: &buf, Encoder: &ConsoleEncoder{}})
+	w := logger.StdLogWriter(context.Background(), LevelInfo, "")
+
+	for _, s := range []string{"hel", "lo\r\nwor", "ld", "\nincomplete"} {
+		if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("unexpected write result: %d, %v", n, err)
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if exp := []string{"INFO hello", "INFO world"}; strings.Join(lines, "|") != strings.Join(exp, "|") {
+		t.Errorf("expected %q, got %q", exp, lines)
+	}
+}
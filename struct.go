@@ -0,0 +1,108 @@
+package blip
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Struct returns a field set with the exported fields of a struct, or a pointer
+// to one. Field keys are taken from the "blip" struct tag and default to the
+// field name. The "omitempty" tag option omits fields with zero values, and
+// the "-" tag omits the field altogether:
+//
+//	type Result struct {
+//		TaskID  int    `blip:"task_id"`
+//		Status  string `blip:"status,omitempty"`
+//		Retries int    `blip:"-"`
+//	}
+//
+// Nested structs are logged as nested objects, fields of embedded structs are
+// promoted. Struct uses reflection and is meant for occasional logging, build
+// field sets by hand in hot paths.
+func Struct(v any) F {
+	f := F{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return f
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return f
+	}
+	structFields(f, rv)
+	return f
+}
+
+func structFields(f map[string]any, rv reflect.Value) {
+	t := rv.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("blip")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := rv.Field(i)
+
+		if sf.Anonymous && name == "" {
+			if ev, ok := structValue(fv); ok {
+				structFields(f, ev)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if hasTagOption(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if sv, ok := structValue(fv); ok {
+			nested := map[string]any{}
+			structFields(nested, sv)
+			f[name] = nested
+			continue
+		}
+		if fv.Kind() == reflect.Pointer && fv.IsNil() && fv.Type().Elem().Kind() == reflect.Struct {
+			f[name] = nil
+			continue
+		}
+		f[name] = fv.Interface()
+	}
+}
+
+// hasTagOption reports whether the comma-separated tag options include the
+// given one, like encoding/json parses them.
+func hasTagOption(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// structValue returns the struct a value holds or points to, unless it is a
+// time, which encoders handle on their own.
+func structValue(v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || v.Type() == timeType {
+		return v, false
+	}
+	return v, true
+}
@@ -0,0 +1,89 @@
+package blip
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type structAddress struct {
+	City string `blip:"city"`
+	Zip  string `blip:"zip,omitempty"`
+}
+
+type structMeta struct {
+	Source string `blip:"source"`
+}
+
+type structResult struct {
+	structMeta
+	TaskID    int            `blip:"task_id"`
+	Status    string         `blip:"status,omitempty"`
+	Retries   int            `blip:"-"`
+	Duration  time.Duration  `blip:"duration"`
+	StartedAt time.Time      `blip:"started_at"`
+	Address   structAddress  `blip:"address"`
+	Billing   *structAddress `blip:"billing"`
+	Untagged  bool
+	internal  string
+}
+
+func TestStruct(t *testing.T) {
+	now := time.Now()
+	f := Struct(&structResult{
+		structMeta: structMeta{Source: "queue"},
+		TaskID:     123456,
+		Retries:    3,
+		Duration:   time.Second,
+		StartedAt:  now,
+		Address:    structAddress{City: "Berlin"},
+		Untagged:   true,
+		internal:   "secret",
+	})
+
+	exp := F{
+		"source":     "queue",
+		"task_id":    123456,
+		"duration":   time.Second,
+		"started_at": now,
+		"address":    map[string]any{"city": "Berlin"},
+		"billing":    nil,
+		"Untagged":   true,
+	}
+	if !reflect.DeepEqual(f, exp) {
+		t.Errorf("expected %v, got %v", exp, f)
+	}
+}
+
+func TestStructOmitEmpty(t *testing.T) {
+	f := Struct(structAddress{City: "Berlin", Zip: "10115"})
+	exp := F{"city": "Berlin", "zip": "10115"}
+	if !reflect.DeepEqual(f, exp) {
+		t.Errorf("expected %v, got %v", exp, f)
+	}
+
+	f = Struct(structAddress{})
+	exp = F{"city": ""}
+	if !reflect.DeepEqual(f, exp) {
+		t.Errorf("expected %v, got %v", exp, f)
+	}
+
+	// omitempty is found among other options
+	f = Struct(struct {
+		ID    int    `blip:"id,string,omitempty"`
+		Name  string `blip:"name,omitempty,string"`
+		Count int    `blip:",omitemptyish"`
+	}{})
+	exp = F{"Count": 0}
+	if !reflect.DeepEqual(f, exp) {
+		t.Errorf("expected %v, got %v", exp, f)
+	}
+}
+
+func TestStructNotStruct(t *testing.T) {
+	for _, v := range []any{nil, 42, (*structAddress)(nil)} {
+		if f := Struct(v); len(f) != 0 {
+			t.Errorf("expected no fields for %#v, got %v", v, f)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package blip
+
+import "sync"
+
+// Subscribe returns a channel that receives a copy of every encoded entry
+// written by the logger, in addition to its output. The channel is buffered
+// with the given size; entries are dropped if the subscriber falls behind, so
+// a slow subscriber never blocks logging. Received entries are shared between
+// subscribers and must not be modified. Entries are passed to subscribers
+// even if the output is Discard.
+//
+// The returned function cancels the subscription and closes the channel.
+func (l *Logger) Subscribe(size int) (<-chan []byte, func()) {
+	ch := make(chan []byte, size)
+	l.lock.Lock()
+	l.subs = append(l.subs, ch)
+	l.updateDiscard()
+	l.lock.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			for i, sub := range l.subs {
+				if sub == ch {
+					l.subs = append(l.subs[:i], l.subs[i+1:]...)
+					break
+				}
+			}
+			l.updateDiscard()
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// publish sends a copy of the entry to all subscribers. Must be called with
+// the lock held.
+func (l *Logger) publish(b []byte) {
+	if len(l.subs) == 0 {
+		return
+	}
+
+	entry := make([]byte, len(b))
+	copy(entry, b)
+	for _, ch := range l.subs {
+		select {
+		case ch <- entry:
+		default:
+			// Subscriber is too slow, drop the entry
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package blip
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Output = io.Discard
+	cfg.Encoder = &ConsoleEncoder{}
+	logger := New(cfg)
+	ctx := context.Background()
+
+	ch, cancel := logger.Subscribe(10)
+	logger.Info(ctx, "First")
+	logger.Info(ctx, "Second")
+	logger.Info(ctx, "Third")
+	cancel()
+	logger.Info(ctx, "After cancel")
+
+	var got []string
+	for entry := range ch {
+		got = append(got, string(entry))
+	}
+	exp := []string{"INFO First\n", "INFO Second\n", "INFO Third\n"}
+	if strings.Join(got, "") != strings.Join(exp, "") {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+
+	// Cancel is idempotent
+	cancel()
+}
+
+func TestSubscribeSlow(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Output = io.Discard
+	cfg.Encoder = &ConsoleEncoder{}
+	logger := New(cfg)
+	ctx := context.Background()
+
+	ch, cancel := logger.Subscribe(2)
+	defer cancel()
+	for range 10 {
+		logger.Info(ctx, "Dropped unless received")
+	}
+	if n := len(ch); n != 2 {
+		t.Errorf("expected 2 buffered entries, got %d", n)
+	}
+}
+
+func TestSubscribeDiscard(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Output = Discard
+	cfg.Encoder = &ConsoleEncoder{}
+	logger := New(cfg)
+	ctx := context.Background()
+
+	ch, cancel := logger.Subscribe(10)
+	logger.Info(ctx, "Subscribed")
+	if err := logger.WriteRaw([]byte("raw")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cancel()
+	if logger.enabled(ctx, LevelInfo) {
+		t.Error("expected entries to be dropped without subscribers")
+	}
+
+	var got []string
+	for entry := range ch {
+		got = append(got, string(entry))
+	}
+	if exp := "INFO Subscribed\nraw\n"; strings.Join(got, "") != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+}